@@ -0,0 +1,283 @@
+// Package admin provides a browser-based administration console for
+// a beanstalkd server, as an http.Handler that can be mounted in an
+// existing server.
+//
+// The console lists tubes and server statistics, shows the jobs at
+// the front of each tube's ready, delayed, and buried queues, and can
+// kick, delete, and pause.
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+// PreviewSize is the number of bytes of each job body shown.
+const PreviewSize = 1024
+
+// Handler serves the console for Conn. Prefix is the path at which
+// the handler is mounted, such as "/beanstalk"; requests outside it
+// get 404.
+type Handler struct {
+	Conn   *beanstalk.Conn
+	Prefix string
+}
+
+// NewHandler returns a Handler for c mounted at prefix.
+func NewHandler(c *beanstalk.Conn, prefix string) *Handler {
+	return &Handler{Conn: c, Prefix: strings.TrimSuffix(prefix, "/")}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, h.Prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, h.Prefix)
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if r.Method == http.MethodPost {
+		if !sameOrigin(r) {
+			http.Error(w, "cross-origin request refused", http.StatusForbidden)
+			return
+		}
+		h.serveAction(w, r, parts)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	switch {
+	case len(parts) == 1 && parts[0] == "":
+		h.serveIndex(w, r)
+	case len(parts) == 2 && parts[0] == "tubes":
+		h.serveTube(w, r, parts[1])
+	case len(parts) == 2 && parts[0] == "jobs":
+		h.serveJob(w, r, parts[1])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// sameOrigin reports whether r was sent by a page from this server,
+// to stop other sites from submitting the console's forms.
+func sameOrigin(r *http.Request) bool {
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		return false
+	}
+	if o := r.Header.Get("Origin"); o != "" {
+		u, err := url.Parse(o)
+		return err == nil && u.Host == r.Host
+	}
+	return true
+}
+
+type tubeRow struct {
+	Name  string
+	Stats map[string]string
+}
+
+func (h *Handler) serveIndex(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.Conn.Stats()
+	if err != nil {
+		h.serveError(w, err)
+		return
+	}
+	names, err := h.Conn.ListTubes()
+	if err != nil {
+		h.serveError(w, err)
+		return
+	}
+	sort.Strings(names)
+	var tubes []tubeRow
+	for _, name := range names {
+		ts, err := beanstalk.NewTube(h.Conn, name).Stats()
+		if err != nil {
+			h.serveError(w, err)
+			return
+		}
+		tubes = append(tubes, tubeRow{name, ts})
+	}
+	h.render(w, "index", map[string]interface{}{
+		"Stats": sortedStats(stats),
+		"Tubes": tubes,
+	})
+}
+
+type peeked struct {
+	State string
+	ID    uint64
+	Body  preview
+	Err   error
+}
+
+func (h *Handler) serveTube(w http.ResponseWriter, r *http.Request, name string) {
+	if err := beanstalk.CheckName(name); err != nil {
+		h.serveError(w, err)
+		return
+	}
+	t := beanstalk.NewTube(h.Conn, name)
+	stats, err := t.Stats()
+	if err != nil {
+		h.serveError(w, err)
+		return
+	}
+	var jobs []peeked
+	for _, p := range []struct {
+		state string
+		peek  func() (uint64, []byte, error)
+	}{
+		{"ready", t.PeekReady},
+		{"delayed", t.PeekDelayed},
+		{"buried", t.PeekBuried},
+	} {
+		id, body, err := p.peek()
+		if errors.Is(err, beanstalk.ErrNotFound) {
+			err = nil
+		}
+		jobs = append(jobs, peeked{p.state, id, newPreview(body), err})
+	}
+	h.render(w, "tube", map[string]interface{}{
+		"Name":  name,
+		"Stats": sortedStats(stats),
+		"Jobs":  jobs,
+	})
+}
+
+func (h *Handler) serveJob(w http.ResponseWriter, r *http.Request, s string) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	stats, err := h.Conn.StatsJob(id)
+	if err != nil {
+		h.serveError(w, err)
+		return
+	}
+	body, err := h.Conn.Peek(id)
+	if err != nil {
+		h.serveError(w, err)
+		return
+	}
+	h.render(w, "job", map[string]interface{}{
+		"ID":    id,
+		"Tube":  stats["tube"],
+		"Stats": sortedStats(stats),
+		"Body":  newPreview(body),
+	})
+}
+
+// serveAction performs a POSTed action and redirects back to the page
+// it came from.
+func (h *Handler) serveAction(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) != 3 {
+		http.NotFound(w, r)
+		return
+	}
+	if parts[0] == "tubes" {
+		if err := beanstalk.CheckName(parts[1]); err != nil {
+			h.serveError(w, err)
+			return
+		}
+	}
+	back := h.Prefix + "/"
+	var err error
+	switch kind, name, action := parts[0], parts[1], parts[2]; {
+	case kind == "tubes" && action == "kick":
+		var bound int
+		if bound, err = strconv.Atoi(r.FormValue("bound")); err == nil {
+			_, err = beanstalk.NewTube(h.Conn, name).Kick(bound)
+		}
+		back += "tubes/" + url.PathEscape(name)
+	case kind == "tubes" && action == "pause":
+		var secs uint64
+		if secs, err = strconv.ParseUint(r.FormValue("delay"), 10, 32); err == nil {
+			err = beanstalk.NewTube(h.Conn, name).Pause(time.Duration(secs) * time.Second)
+		}
+		back += "tubes/" + url.PathEscape(name)
+	case kind == "jobs" && (action == "delete" || action == "kick"):
+		var id uint64
+		if id, err = strconv.ParseUint(name, 10, 64); err != nil {
+			break
+		}
+		if action == "delete" {
+			err = h.Conn.Delete(id)
+		} else {
+			err = h.Conn.KickJob(id)
+		}
+		if tube := r.FormValue("tube"); tube != "" {
+			back += "tubes/" + url.PathEscape(tube)
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		h.serveError(w, err)
+		return
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+func (h *Handler) serveError(w http.ResponseWriter, err error) {
+	code := http.StatusBadGateway
+	if errors.Is(err, beanstalk.ErrNotFound) {
+		code = http.StatusNotFound
+	}
+	var nerr beanstalk.NameError
+	if errors.As(err, &nerr) || errors.Is(err, strconv.ErrSyntax) || errors.Is(err, strconv.ErrRange) {
+		code = http.StatusBadRequest
+	}
+	w.WriteHeader(code)
+	h.render(w, "error", map[string]interface{}{"Err": err})
+}
+
+func (h *Handler) render(w http.ResponseWriter, name string, data map[string]interface{}) {
+	data["Prefix"] = h.Prefix
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := templates.ExecuteTemplate(w, name, data); err != nil {
+		fmt.Fprintf(w, "<p>template error: %s</p>", template.HTMLEscapeString(err.Error()))
+	}
+}
+
+type stat struct {
+	Key, Value string
+}
+
+func sortedStats(d map[string]string) []stat {
+	var s []stat
+	for k, v := range d {
+		s = append(s, stat{k, v})
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Key < s[j].Key })
+	return s
+}
+
+// A preview is the start of a job body, as text.
+type preview struct {
+	Text      string
+	Size      int
+	Truncated bool
+	Binary    bool
+}
+
+func newPreview(body []byte) preview {
+	p := preview{Size: len(body)}
+	if len(body) > PreviewSize {
+		body, p.Truncated = body[:PreviewSize], true
+	}
+	p.Binary = !utf8.Valid(body)
+	p.Text = strings.ToValidUTF8(string(body), "�")
+	return p
+}
@@ -0,0 +1,135 @@
+package admin
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+// fakeConn replies with a canned response and records what it is sent.
+type fakeConn struct {
+	io.Reader
+	sent bytes.Buffer
+}
+
+func (f *fakeConn) Write(b []byte) (int, error) { return f.sent.Write(b) }
+func (f *fakeConn) Close() error                { return nil }
+
+func serve(t *testing.T, req *http.Request, resp string) (*httptest.ResponseRecorder, string) {
+	f := &fakeConn{Reader: strings.NewReader(resp)}
+	h := NewHandler(beanstalk.NewConn(f), "/admin/")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w, f.sent.String()
+}
+
+func TestIndex(t *testing.T) {
+	resp := "OK 12\r\n---\npid: 42\n\r\n" +
+		"OK 14\r\n---\n- default\n\r\n" +
+		"OK 40\r\n---\nname: default\ncurrent-jobs-ready: 7\n\r\n"
+	w, sent := serve(t, httptest.NewRequest("GET", "/admin/", nil), resp)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if sent != "stats\r\nlist-tubes\r\nstats-tube default\r\n" {
+		t.Fatalf("sent %#v", sent)
+	}
+	body := w.Body.String()
+	for _, s := range []string{`href="/admin/tubes/default"`, "<td class=\"n\">7</td>", "pid"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("page lacks %#v", s)
+		}
+	}
+}
+
+func TestTube(t *testing.T) {
+	resp := "OK 14\r\n---\nname: foo\n\r\n" +
+		"FOUND 3 9\r\n<b>hi</b>\r\n" +
+		"NOT_FOUND\r\n" +
+		"FOUND 4 3\r\n\xff\x00x\r\n"
+	w, sent := serve(t, httptest.NewRequest("GET", "/admin/tubes/foo", nil), resp)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if !strings.Contains(sent, "peek-buried\r\n") {
+		t.Fatalf("sent %#v", sent)
+	}
+	body := w.Body.String()
+	for _, s := range []string{"&lt;b&gt;hi&lt;/b&gt;", `href="/admin/jobs/4"`, "not valid UTF-8", "<p>none</p>"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("page lacks %#v", s)
+		}
+	}
+}
+
+func TestAction(t *testing.T) {
+	req := httptest.NewRequest("POST", "/admin/jobs/4/delete", strings.NewReader(url.Values{"tube": {"foo"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w, sent := serve(t, req, "DELETED\r\n")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin/tubes/foo" {
+		t.Fatalf("status %d location %q", w.Code, w.Header().Get("Location"))
+	}
+	if sent != "delete 4\r\n" {
+		t.Fatalf("sent %#v", sent)
+	}
+}
+
+func TestBadTubeName(t *testing.T) {
+	f := &fakeConn{Reader: strings.NewReader("OK 12\r\n---\npid: 42\n\r\nOK 4\r\n---\n\r\n")}
+	h := NewHandler(beanstalk.NewConn(f), "/admin")
+	for _, req := range []*http.Request{
+		httptest.NewRequest("POST", "/admin/tubes/bad%21name/kick?bound=1", nil),
+		httptest.NewRequest("GET", "/admin/tubes/bad%21name", nil),
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s %s: status %d", req.Method, req.URL, w.Code)
+		}
+	}
+	done := make(chan int, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/admin/", nil))
+		done <- w.Code
+	}()
+	select {
+	case code := <-done:
+		if code != http.StatusOK {
+			t.Fatalf("status %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request after a bad tube name hung")
+	}
+	if got := f.sent.String(); got != "stats\r\nlist-tubes\r\n" {
+		t.Fatalf("sent %#v", got)
+	}
+}
+
+func TestActionCrossOrigin(t *testing.T) {
+	req := httptest.NewRequest("POST", "/admin/tubes/foo/pause", strings.NewReader("delay=60"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "http://evil.example")
+	w, sent := serve(t, req, "")
+	if w.Code != http.StatusForbidden || sent != "" {
+		t.Fatalf("status %d sent %#v", w.Code, sent)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	w, _ := serve(t, httptest.NewRequest("GET", "/admin/jobs/9", nil), "NOT_FOUND\r\n")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status %d", w.Code)
+	}
+	w, _ = serve(t, httptest.NewRequest("GET", "/other", nil), "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status %d", w.Code)
+	}
+}
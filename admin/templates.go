@@ -0,0 +1,76 @@
+package admin
+
+import "html/template"
+
+var templates = template.Must(template.New("").Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>beanstalkd{{with .Name}} – {{.}}{{end}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+td.n { text-align: right; }
+pre { background: #f4f4f4; padding: 0.5em; white-space: pre-wrap; max-width: 60em; }
+form { display: inline; }
+</style></head><body>
+<p><a href="{{.Prefix}}/">tubes</a></p>
+{{end}}
+
+{{define "stats"}}<table>{{range .}}<tr><th>{{.Key}}</th><td>{{.Value}}</td></tr>{{end}}</table>{{end}}
+
+{{define "preview"}}<pre>{{.Text}}</pre>
+<p>{{.Size}} bytes{{if .Truncated}}, truncated{{end}}{{if .Binary}}, not valid UTF-8{{end}}</p>{{end}}
+
+{{define "index"}}{{template "head" .}}
+<h1>Tubes</h1>
+<table>
+<tr><th>tube</th><th>ready</th><th>urgent</th><th>reserved</th><th>delayed</th><th>buried</th><th>total</th><th>paused</th></tr>
+{{range .Tubes}}<tr>
+<td><a href="{{$.Prefix}}/tubes/{{.Name}}">{{.Name}}</a></td>
+<td class="n">{{index .Stats "current-jobs-ready"}}</td>
+<td class="n">{{index .Stats "current-jobs-urgent"}}</td>
+<td class="n">{{index .Stats "current-jobs-reserved"}}</td>
+<td class="n">{{index .Stats "current-jobs-delayed"}}</td>
+<td class="n">{{index .Stats "current-jobs-buried"}}</td>
+<td class="n">{{index .Stats "total-jobs"}}</td>
+<td class="n">{{index .Stats "pause-time-left"}}</td>
+</tr>{{end}}
+</table>
+<h2>Server</h2>
+{{template "stats" .Stats}}
+</body></html>{{end}}
+
+{{define "tube"}}{{template "head" .}}
+<h1>Tube {{.Name}}</h1>
+<form method="post" action="{{.Prefix}}/tubes/{{.Name}}/kick">
+<input name="bound" value="100" size="5"> <button>kick</button></form>
+<form method="post" action="{{.Prefix}}/tubes/{{.Name}}/pause">
+<input name="delay" value="60" size="5"> seconds <button>pause</button></form>
+{{range .Jobs}}
+<h2>Next {{.State}} job</h2>
+{{if .Err}}<p>error: {{.Err}}</p>
+{{else if .ID}}<p><a href="{{$.Prefix}}/jobs/{{.ID}}">job {{.ID}}</a>
+<form method="post" action="{{$.Prefix}}/jobs/{{.ID}}/delete"><input type="hidden" name="tube" value="{{$.Name}}"><button>delete</button></form>
+{{if ne .State "ready"}}<form method="post" action="{{$.Prefix}}/jobs/{{.ID}}/kick"><input type="hidden" name="tube" value="{{$.Name}}"><button>kick</button></form>{{end}}
+</p>
+{{template "preview" .Body}}
+{{else}}<p>none</p>{{end}}
+{{end}}
+<h2>Statistics</h2>
+{{template "stats" .Stats}}
+</body></html>{{end}}
+
+{{define "job"}}{{template "head" .}}
+<h1>Job {{.ID}}</h1>
+<p>in tube <a href="{{.Prefix}}/tubes/{{.Tube}}">{{.Tube}}</a>
+<form method="post" action="{{.Prefix}}/jobs/{{.ID}}/delete"><input type="hidden" name="tube" value="{{.Tube}}"><button>delete</button></form>
+<form method="post" action="{{.Prefix}}/jobs/{{.ID}}/kick"><input type="hidden" name="tube" value="{{.Tube}}"><button>kick</button></form></p>
+{{template "preview" .Body}}
+{{template "stats" .Stats}}
+</body></html>{{end}}
+
+{{define "error"}}{{template "head" .}}
+<h1>Error</h1>
+<p>{{.Err}}</p>
+</body></html>{{end}}
+`))
@@ -0,0 +1,84 @@
+package beanstalk
+
+import (
+	"errors"
+)
+
+// A BuriedAction tells FilterBuried what to do with a buried job.
+type BuriedAction int
+
+// Actions for FilterBuried.
+const (
+	KeepBuried    BuriedAction = iota // leave the job buried
+	KickBuried                        // move the job to the ready queue
+	DeleteBuried                      // delete the job
+	RewriteBuried                     // replace the job with a ready job with a new body
+)
+
+// A BuriedFunc decides what to do with a buried job. For
+// RewriteBuried, it also returns the new body.
+type BuriedFunc func(id uint64, body []byte) (action BuriedAction, newBody []byte)
+
+// FilterBuried calls f once for each job buried in t, in bury order,
+// and applies the action it returns. Rewritten jobs keep their
+// priority and TTR but get a new id. FilterBuried returns the number
+// of jobs kicked, deleted, or rewritten.
+//
+// To reach the jobs behind it, each kept job is reserved and buried
+// again, which moves it to the back of the holding area.
+func (t *Tube) FilterBuried(f BuriedFunc) (n int, err error) {
+	var firstKept uint64
+	for {
+		id, body, err := t.PeekBuried()
+		if errors.Is(err, ErrNotFound) || err == nil && id == firstKept {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		action, newBody := f(id, body)
+		switch action {
+		case KeepBuried:
+			if firstKept == 0 {
+				firstKept = id
+			}
+			err = t.rebury(id)
+		case KickBuried:
+			err = t.Conn.KickJob(id)
+		case DeleteBuried:
+			err = t.Conn.Delete(id)
+		case RewriteBuried:
+			err = t.rewrite(id, newBody)
+		default:
+			err = errors.New("unknown buried action")
+		}
+		if err != nil {
+			return n, err
+		}
+		if action != KeepBuried {
+			n++
+		}
+	}
+}
+
+func (t *Tube) rebury(id uint64) error {
+	pri, err := t.Conn.jobPri(id)
+	if err != nil {
+		return err
+	}
+	if _, err = t.Conn.ReserveJob(id); err != nil {
+		return err
+	}
+	return t.Conn.Bury(id, pri)
+}
+
+func (t *Tube) rewrite(id uint64, body []byte) error {
+	info, err := t.Conn.statsJob(id)
+	if err != nil {
+		return err
+	}
+	if _, err = t.Put(body, info.pri, 0, info.ttr); err != nil {
+		return err
+	}
+	return t.Conn.Delete(id)
+}
@@ -0,0 +1,62 @@
+package beanstalk
+
+import (
+	"testing"
+)
+
+func TestFilterBuried(t *testing.T) {
+	c := NewConn(mock(
+		"peek-buried\r\nstats-job 1\r\nreserve-job 1\r\nbury 1 4\r\n"+
+			"peek-buried\r\nkick-job 2\r\n"+
+			"peek-buried\r\ndelete 3\r\n"+
+			"peek-buried\r\nstats-job 4\r\nput 4 0 60 2\r\nyy\r\ndelete 4\r\n"+
+			"peek-buried\r\n",
+		"FOUND 1 1\r\nk\r\nOK 11\r\n---\npri: 4\n\r\nRESERVED 1 1\r\nk\r\nBURIED\r\n"+
+			"FOUND 2 1\r\nx\r\nKICKED\r\n"+
+			"FOUND 3 1\r\nd\r\nDELETED\r\n"+
+			"FOUND 4 1\r\ny\r\nOK 19\r\n---\npri: 4\nttr: 60\n\r\nINSERTED 5\r\nDELETED\r\n"+
+			"FOUND 1 1\r\nk\r\n",
+	))
+	var seen []uint64
+	n, err := c.FilterBuried(func(id uint64, body []byte) (BuriedAction, []byte) {
+		seen = append(seen, id)
+		switch string(body) {
+		case "x":
+			return KickBuried, nil
+		case "d":
+			return DeleteBuried, nil
+		case "y":
+			return RewriteBuried, []byte("yy")
+		}
+		return KeepBuried, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatal("expected 3, got", n)
+	}
+	if len(seen) != 4 {
+		t.Fatal("expected 4 jobs visited, got", seen)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFilterBuriedEmpty(t *testing.T) {
+	c := NewConn(mock("peek-buried\r\n", "NOT_FOUND\r\n"))
+	n, err := c.FilterBuried(func(uint64, []byte) (BuriedAction, []byte) {
+		t.Fatal("unexpected call")
+		return KeepBuried, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatal("expected 0, got", n)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
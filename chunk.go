@@ -0,0 +1,216 @@
+package beanstalk
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// Chunk set errors. Jobs belonging to a failed set are buried.
+var (
+	ErrChunkCorrupt    = errors.New("chunked job failed checksum")
+	ErrChunkIncomplete = errors.New("chunked job incomplete")
+)
+
+// MaxChunkParts is the most parts a chunked job may be split into.
+// An Assembler treats a part claiming more as corrupt.
+const MaxChunkParts = 1 << 16
+
+var chunkMagic = []byte("\x00BSK")
+
+// chunk header: magic, 16-byte set id, part index, part count,
+// then the SHA-256 of the whole body
+const chunkHeaderLen = 4 + 16 + 4 + 4 + sha256.Size
+
+// PutChunked splits body into parts of at most size bytes and puts
+// each part into tube t as a separate job, returning their ids.
+// See Put for the meaning of the other arguments.
+//
+// If a put fails, the parts already put are deleted.
+func (t *Tube) PutChunked(body []byte, size int, pri uint32, delay, ttr time.Duration) (ids []uint64, err error) {
+	if size <= 0 {
+		return nil, errors.New("chunk size must be positive")
+	}
+	set, err := newBlobKey()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	n := (len(body) + size - 1) / size
+	if n == 0 {
+		n = 1
+	}
+	if n > MaxChunkParts {
+		return nil, errors.New("chunk size too small: too many parts")
+	}
+	for i := 0; i < n; i++ {
+		part := body[i*size:]
+		if len(part) > size {
+			part = part[:size]
+		}
+		var b bytes.Buffer
+		b.Write(chunkMagic)
+		b.WriteString(set[:16])
+		binary.Write(&b, binary.BigEndian, [2]uint32{uint32(i), uint32(n)})
+		b.Write(sum[:])
+		b.Write(part)
+		id, err := t.Put(b.Bytes(), pri, delay, ttr)
+		if err != nil {
+			for _, id := range ids {
+				t.Conn.Delete(id)
+			}
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// An Assembler reserves jobs from a TubeSet, reassembling bodies
+// put with PutChunked. Parts of incomplete sets are held reserved
+// until the rest arrive, so the TTR of chunked jobs should exceed
+// Timeout.
+type Assembler struct {
+	TubeSet *TubeSet
+
+	// Timeout is how long to wait for the remaining parts of a set
+	// after its first part is reserved. If zero, sets never time out.
+	Timeout time.Duration
+
+	sets map[string]*chunkSet
+}
+
+type chunkSet struct {
+	start time.Time
+	sum   []byte
+	ids   []uint64
+	parts [][]byte
+	have  int
+}
+
+// NewAssembler returns a new Assembler for the given tube set.
+func NewAssembler(ts *TubeSet, timeout time.Duration) *Assembler {
+	return &Assembler{TubeSet: ts, Timeout: timeout}
+}
+
+// Reserve reserves jobs until it can return a complete body, and
+// returns the ids of all jobs making up that body, which the caller
+// should delete once done. Jobs not put with PutChunked are returned
+// as they are, with a single id.
+//
+// If a set fails its checksum or times out waiting for missing parts,
+// its reserved parts are buried and Reserve returns their ids along
+// with ErrChunkCorrupt or ErrChunkIncomplete.
+func (a *Assembler) Reserve(timeout time.Duration) (ids []uint64, body []byte, err error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		if ids := a.expire(); ids != nil {
+			return ids, nil, ErrChunkIncomplete
+		}
+		wait := time.Until(deadline)
+		if wait < 0 {
+			wait = 0
+		}
+		if next := a.nextExpiry(); next >= 0 && next < wait {
+			wait = next
+		}
+		// the server only understands whole seconds
+		wait = (wait + time.Second - 1) / time.Second * time.Second
+		id, b, err := a.TubeSet.Reserve(wait)
+		if errors.Is(err, ErrTimeout) && time.Now().Before(deadline) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if !bytes.HasPrefix(b, chunkMagic) || len(b) < chunkHeaderLen {
+			return []uint64{id}, b, nil
+		}
+		if ids, body, err := a.add(id, b); ids != nil {
+			return ids, body, err
+		}
+	}
+}
+
+func (a *Assembler) add(id uint64, b []byte) ([]uint64, []byte, error) {
+	key := string(b[4:20])
+	i := binary.BigEndian.Uint32(b[20:24])
+	n := binary.BigEndian.Uint32(b[24:28])
+	sum := b[28:chunkHeaderLen]
+	if a.sets == nil {
+		a.sets = make(map[string]*chunkSet)
+	}
+	s := a.sets[key]
+	if s == nil {
+		// The part count comes from the job body; don't trust it with
+		// an allocation.
+		size := n
+		if size > MaxChunkParts {
+			size = 0
+		}
+		s = &chunkSet{start: time.Now(), sum: sum, parts: make([][]byte, size)}
+		a.sets[key] = s
+	}
+	s.ids = append(s.ids, id)
+	if n != uint32(len(s.parts)) || i >= n || s.parts[i] != nil || !bytes.Equal(sum, s.sum) {
+		return a.fail(key), nil, ErrChunkCorrupt
+	}
+	s.parts[i] = b[chunkHeaderLen:]
+	s.have++
+	if s.have < len(s.parts) {
+		return nil, nil, nil
+	}
+	body := bytes.Join(s.parts, nil)
+	if got := sha256.Sum256(body); !bytes.Equal(got[:], s.sum) {
+		return a.fail(key), nil, ErrChunkCorrupt
+	}
+	delete(a.sets, key)
+	return s.ids, body, nil
+}
+
+// expire buries the parts of the first timed-out set it finds
+// and returns their ids.
+func (a *Assembler) expire() []uint64 {
+	if a.Timeout <= 0 {
+		return nil
+	}
+	for key, s := range a.sets {
+		if time.Since(s.start) >= a.Timeout {
+			return a.fail(key)
+		}
+	}
+	return nil
+}
+
+// nextExpiry returns the time until the next set times out,
+// or -1 if there are no incomplete sets or sets never time out.
+func (a *Assembler) nextExpiry() time.Duration {
+	next := time.Duration(-1)
+	if a.Timeout <= 0 {
+		return next
+	}
+	for _, s := range a.sets {
+		d := a.Timeout - time.Since(s.start)
+		if d < 0 {
+			d = 0
+		}
+		if next < 0 || d < next {
+			next = d
+		}
+	}
+	return next
+}
+
+func (a *Assembler) fail(key string) []uint64 {
+	s := a.sets[key]
+	delete(a.sets, key)
+	c := a.TubeSet.Conn
+	for _, id := range s.ids {
+		if pri, err := c.jobPri(id); err == nil {
+			c.Bury(id, pri)
+		}
+	}
+	return s.ids
+}
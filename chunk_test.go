@@ -0,0 +1,175 @@
+package beanstalk
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// putBodies extracts the bodies of the put commands in s.
+func putBodies(t *testing.T, s string) []string {
+	var bodies []string
+	for s != "" {
+		i := strings.Index(s, "\r\n")
+		f := strings.Fields(s[:i])
+		n, err := strconv.Atoi(f[len(f)-1])
+		if f[0] != "put" || err != nil {
+			t.Fatalf("unexpected command %#v", s[:i])
+		}
+		s = s[i+2:]
+		bodies = append(bodies, s[:n])
+		s = s[n+2:]
+	}
+	return bodies
+}
+
+func TestChunked(t *testing.T) {
+	var sent string
+	c := NewConn(recordIO(&sent, "INSERTED 1\r\nINSERTED 2\r\nINSERTED 3\r\n"))
+	ids, err := c.PutChunked([]byte("hello world"), 4, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != "[1 2 3]" {
+		t.Fatal("expected [1 2 3], got", ids)
+	}
+	parts := putBodies(t, sent)
+	if len(parts) != 3 {
+		t.Fatal("expected 3 parts, got", len(parts))
+	}
+
+	var recv, send string
+	for i, j := range []int{2, 0, 1} {
+		recv += "reserve-with-timeout 1\r\n"
+		send += fmt.Sprintf("RESERVED %d %d\r\n%s\r\n", i+1, len(parts[j]), parts[j])
+	}
+	c = NewConn(mock(recv, send))
+	a := NewAssembler(&c.TubeSet, time.Minute)
+	ids, body, err := a.Reserve(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != "[1 2 3]" || string(body) != "hello world" {
+		t.Fatalf("expected [1 2 3] %#v, got %v %#v", "hello world", ids, string(body))
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAssemblerPlain(t *testing.T) {
+	c := NewConn(mock("reserve-with-timeout 1\r\n", "RESERVED 1 1\r\nx\r\n"))
+	ids, body, err := NewAssembler(&c.TubeSet, 0).Reserve(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != "[1]" || string(body) != "x" {
+		t.Fatalf("expected [1] %#v, got %v %#v", "x", ids, string(body))
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAssemblerCorrupt(t *testing.T) {
+	var sent string
+	c := NewConn(recordIO(&sent, "INSERTED 1\r\nINSERTED 2\r\n"))
+	if _, err := c.PutChunked([]byte("hello"), 3, 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	parts := putBodies(t, sent)
+	p0 := parts[0][:len(parts[0])-1] + "X"
+	c = NewConn(mock(
+		"reserve-with-timeout 1\r\nreserve-with-timeout 1\r\n"+
+			"stats-job 1\r\nbury 1 0\r\nstats-job 2\r\nbury 2 0\r\n",
+		fmt.Sprintf("RESERVED 1 %d\r\n%s\r\n", len(p0), p0)+
+			fmt.Sprintf("RESERVED 2 %d\r\n%s\r\n", len(parts[1]), parts[1])+
+			"OK 11\r\n---\npri: 0\n\r\nBURIED\r\nOK 11\r\n---\npri: 0\n\r\nBURIED\r\n",
+	))
+	ids, _, err := NewAssembler(&c.TubeSet, time.Minute).Reserve(time.Second)
+	if !errors.Is(err, ErrChunkCorrupt) {
+		t.Fatal("expected ErrChunkCorrupt, got", err)
+	}
+	if fmt.Sprint(ids) != "[1 2]" {
+		t.Fatal("expected [1 2], got", ids)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAssemblerTooManyParts(t *testing.T) {
+	var b bytes.Buffer
+	b.Write(chunkMagic)
+	b.WriteString("0123456789abcdef")
+	binary.Write(&b, binary.BigEndian, [2]uint32{0, 1 << 31})
+	b.Write(make([]byte, sha256.Size))
+	p := b.String()
+	c := NewConn(mock(
+		"reserve-with-timeout 1\r\nstats-job 1\r\nbury 1 0\r\n",
+		fmt.Sprintf("RESERVED 1 %d\r\n%s\r\n", len(p), p)+
+			"OK 11\r\n---\npri: 0\n\r\nBURIED\r\n",
+	))
+	a := NewAssembler(&c.TubeSet, 0)
+	ids, _, err := a.Reserve(time.Second)
+	if !errors.Is(err, ErrChunkCorrupt) {
+		t.Fatal("expected ErrChunkCorrupt, got", err)
+	}
+	if fmt.Sprint(ids) != "[1]" {
+		t.Fatal("expected [1], got", ids)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAssemblerNoTimeout(t *testing.T) {
+	var sent string
+	c := NewConn(recordIO(&sent, "INSERTED 1\r\nINSERTED 2\r\n"))
+	if _, err := c.PutChunked([]byte("hello"), 3, 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	p := putBodies(t, sent)[0]
+	sent = ""
+	c = NewConn(recordIO(&sent, fmt.Sprintf("RESERVED 1 %d\r\n%s\r\n", len(p), p)))
+	a := NewAssembler(&c.TubeSet, 0)
+	if _, _, err := a.Reserve(time.Second); err == nil {
+		t.Fatal("expected error")
+	}
+	// the incomplete set must not shorten the wait
+	exp := "reserve-with-timeout 1\r\nreserve-with-timeout 1\r\n"
+	if sent != exp {
+		t.Fatalf("expected %#v, got %#v", exp, sent)
+	}
+}
+
+func TestAssemblerIncomplete(t *testing.T) {
+	var sent string
+	c := NewConn(recordIO(&sent, "INSERTED 1\r\nINSERTED 2\r\n"))
+	if _, err := c.PutChunked([]byte("hello"), 3, 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	p := putBodies(t, sent)[0]
+	c = NewConn(mock(
+		"reserve-with-timeout 1\r\nstats-job 1\r\nbury 1 0\r\n",
+		fmt.Sprintf("RESERVED 1 %d\r\n%s\r\n", len(p), p)+
+			"OK 11\r\n---\npri: 0\n\r\nBURIED\r\n",
+	))
+	a := NewAssembler(&c.TubeSet, time.Nanosecond)
+	ids, _, err := a.Reserve(time.Second)
+	if !errors.Is(err, ErrChunkIncomplete) {
+		t.Fatal("expected ErrChunkIncomplete, got", err)
+	}
+	if fmt.Sprint(ids) != "[1]" {
+		t.Fatal("expected [1], got", ids)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,187 @@
+package beanstalk
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var claimMagic = []byte("\x00BSC")
+
+// A BlobStore stores payloads outside the server, indexed by key.
+type BlobStore interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+// DirStore is a BlobStore that keeps each blob in a file in the named
+// directory. The directory must exist.
+type DirStore string
+
+func (d DirStore) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\`) || key[0] == '.' {
+		return "", fmt.Errorf("bad blob key %q", key)
+	}
+	return filepath.Join(string(d), key), nil
+}
+
+// Put writes data to the file named key, replacing it atomically.
+func (d DirStore) Put(key string, data []byte) error {
+	name, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(string(d), ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Get reads the file named key.
+func (d DirStore) Get(key string) ([]byte, error) {
+	name, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(name)
+}
+
+// Delete removes the file named key. It is not an error if the
+// file does not exist.
+func (d DirStore) Delete(key string) error {
+	name, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(name); os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// ClaimCheck puts bodies too large for the server in Store and
+// enqueues a small reference job in their place. Jobs put with a
+// ClaimCheck should be reserved and deleted with the same ClaimCheck,
+// so references are resolved and blobs are removed along with their
+// jobs.
+type ClaimCheck struct {
+	Store BlobStore
+
+	// Bodies longer than Threshold bytes are stored in Store.
+	// If zero, the server's max-job-size is used, as read on the
+	// first Put.
+	Threshold int
+
+	mu         sync.Mutex
+	maxJobSize int // cached max-job-size, or 0
+}
+
+// Put puts body into tube t as in Tube.Put, storing it in cc.Store
+// if it is larger than the threshold.
+func (cc *ClaimCheck) Put(t *Tube, body []byte, pri uint32, delay, ttr time.Duration) (id uint64, err error) {
+	threshold, err := cc.threshold(t.Conn)
+	if err != nil {
+		return 0, err
+	}
+	if len(body) <= threshold {
+		return t.Put(body, pri, delay, ttr)
+	}
+	key, err := newBlobKey()
+	if err != nil {
+		return 0, err
+	}
+	if err = cc.Store.Put(key, body); err != nil {
+		return 0, err
+	}
+	id, err = t.Put(append(claimMagic[:len(claimMagic):len(claimMagic)], key...), pri, delay, ttr)
+	if err != nil {
+		cc.Store.Delete(key)
+		return 0, err
+	}
+	return id, nil
+}
+
+// Reserve reserves a job as in TubeSet.Reserve and replaces a
+// reference body with the stored payload. If the payload cannot
+// be retrieved, Reserve returns the id of the job along with the error.
+func (cc *ClaimCheck) Reserve(t *TubeSet, timeout time.Duration) (id uint64, body []byte, err error) {
+	id, body, err = t.Reserve(timeout)
+	if err != nil {
+		return 0, nil, err
+	}
+	if key, ok := claimKey(body); ok {
+		body, err = cc.Store.Get(key)
+	}
+	return id, body, err
+}
+
+// Delete deletes the given job as in Conn.Delete, then removes its
+// payload from cc.Store if it has one.
+func (cc *ClaimCheck) Delete(c *Conn, id uint64) error {
+	body, err := c.Peek(id)
+	if err != nil {
+		return err
+	}
+	if err = c.Delete(id); err != nil {
+		return err
+	}
+	if key, ok := claimKey(body); ok {
+		return cc.Store.Delete(key)
+	}
+	return nil
+}
+
+func (cc *ClaimCheck) threshold(c *Conn) (int, error) {
+	if cc.Threshold > 0 {
+		return cc.Threshold, nil
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.maxJobSize > 0 {
+		return cc.maxJobSize, nil
+	}
+	stats, err := c.Stats()
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(stats["max-job-size"])
+	if err != nil {
+		return 0, ConnError{c, "stats", err}
+	}
+	cc.maxJobSize = n
+	return n, nil
+}
+
+func claimKey(body []byte) (string, bool) {
+	if !bytes.HasPrefix(body, claimMagic) {
+		return "", false
+	}
+	return string(body[len(claimMagic):]), true
+}
+
+func newBlobKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,98 @@
+package beanstalk
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDirStore(t *testing.T) {
+	d := DirStore(t.TempDir())
+	if err := d.Put("a", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	b, err := d.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "x" {
+		t.Fatalf("expected %#v, got %#v", "x", string(b))
+	}
+	if err = d.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = d.Get("a"); !os.IsNotExist(err) {
+		t.Fatal("expected not exist, got", err)
+	}
+	if err = d.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirStoreBadKey(t *testing.T) {
+	d := DirStore(t.TempDir())
+	for _, key := range []string{"", "../a", "a/b", ".."} {
+		if err := d.Put(key, nil); err == nil {
+			t.Fatalf("%#v: expected error", key)
+		}
+	}
+}
+
+func TestClaimCheckSmall(t *testing.T) {
+	c := NewConn(mock(
+		"stats\r\nput 0 0 0 3\r\nfoo\r\nput 0 0 0 3\r\nbar\r\n",
+		"OK 21\r\n---\nmax-job-size: 10\n\r\nINSERTED 1\r\nINSERTED 2\r\n",
+	))
+	cc := &ClaimCheck{Store: DirStore(t.TempDir())}
+	// max-job-size is read only once
+	for i, body := range []string{"foo", "bar"} {
+		id, err := cc.Put(&c.Tube, []byte(body), 0, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != uint64(i+1) {
+			t.Fatalf("expected %d, got %d", i+1, id)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClaimCheck(t *testing.T) {
+	dir := t.TempDir()
+	cc := &ClaimCheck{Store: DirStore(dir), Threshold: 2}
+
+	var sent string
+	c := NewConn(recordIO(&sent, "INSERTED 1\r\n"))
+	if _, err := cc.Put(&c.Tube, []byte("foo"), 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	ref := strings.TrimSuffix(sent[strings.Index(sent, "\r\n")+2:], "\r\n")
+	key, ok := claimKey([]byte(ref))
+	if !ok {
+		t.Fatalf("expected reference body, got %#v", ref)
+	}
+
+	c = NewConn(mock(
+		"reserve-with-timeout 1\r\npeek 1\r\ndelete 1\r\n",
+		"RESERVED 1 36\r\n"+ref+"\r\nFOUND 1 36\r\n"+ref+"\r\nDELETED\r\n",
+	))
+	id, body, err := cc.Reserve(&c.TubeSet, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 || string(body) != "foo" {
+		t.Fatalf("expected 1 %#v, got %d %#v", "foo", id, string(body))
+	}
+	if err = cc.Delete(c, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = DirStore(dir).Get(key); !os.IsNotExist(err) {
+		t.Fatal("expected blob to be deleted, got", err)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,42 @@
+// Command beanstalk-admin serves a browser console for a beanstalkd
+// server.
+//
+// Usage:
+//
+//	beanstalk-admin [-addr host:port] [-listen host:port] [-prefix path]
+//
+// The console has no authentication of its own and can delete jobs,
+// so by default it listens only on the loopback interface.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/beanstalkd/go-beanstalk"
+	"github.com/beanstalkd/go-beanstalk/admin"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:11300", "server address")
+	listen := flag.String("listen", "127.0.0.1:8080", "HTTP listen address")
+	prefix := flag.String("prefix", "", "path at which to serve the console")
+	flag.Parse()
+
+	c, err := beanstalk.Dial("tcp", *addr)
+	if err != nil {
+		fatal(err)
+	}
+	defer c.Close()
+
+	h := admin.NewHandler(c, *prefix)
+	fmt.Fprintf(os.Stderr, "serving %s on http://%s%s/\n", *addr, *listen, h.Prefix)
+	fatal(http.ListenAndServe(*listen, h))
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "beanstalk-admin:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,39 @@
+// Command beanstalk-gateway serves the HTTP interface to beanstalkd
+// described in package gateway.
+//
+// Usage:
+//
+//	beanstalk-gateway [-addr host:port] [-listen host:port] [-pool n] [-max-timeout d]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/beanstalkd/go-beanstalk"
+	"github.com/beanstalkd/go-beanstalk/gateway"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:11300", "server address")
+	listen := flag.String("listen", "127.0.0.1:8080", "HTTP listen address")
+	pool := flag.Int("pool", gateway.DefaultPoolSize, "number of server connections")
+	maxTimeout := flag.Duration("max-timeout", gateway.DefaultMaxTimeout, "longest reserve timeout allowed")
+	maxBody := flag.Int64("max-body", 0, "largest job body accepted, in bytes (0 for no limit)")
+	flag.Parse()
+
+	g := gateway.New(func() (*beanstalk.Conn, error) {
+		return beanstalk.DialTimeout("tcp", *addr, 10*time.Second)
+	})
+	g.Size = *pool
+	g.MaxTimeout = *maxTimeout
+	g.MaxBody = *maxBody
+	defer g.Close()
+
+	fmt.Fprintf(os.Stderr, "serving %s on http://%s/\n", *addr, *listen)
+	fmt.Fprintln(os.Stderr, "beanstalk-gateway:", http.ListenAndServe(*listen, g))
+	os.Exit(1)
+}
@@ -0,0 +1,135 @@
+// Command beanstalk-proxy relays the beanstalkd protocol between
+// clients and a server, logging every command as a JSON line and
+// optionally refusing some.
+//
+// Usage:
+//
+//	beanstalk-proxy [-addr host:port] [-listen host:port] [-max-job-size n] [-deny-default] [-rule rule]...
+//
+// Each rule has the form
+//
+//	allow|deny:commands:tubes
+//
+// where commands and tubes are comma-separated lists, and tubes are
+// patterns as in path.Match. An empty list matches anything. Rules are
+// checked in the order given. For example, to let only the tube
+// "billing" be kicked or have jobs deleted in it:
+//
+//	beanstalk-proxy -rule allow:delete,kick,kick-job:billing -rule deny:delete,kick,kick-job:
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+type rules []beanstalk.ProxyRule
+
+func (r *rules) String() string { return fmt.Sprint(*r) }
+
+func (r *rules) Set(s string) error {
+	rule, err := parseRule(s)
+	if err != nil {
+		return err
+	}
+	*r = append(*r, rule)
+	return nil
+}
+
+func parseRule(s string) (beanstalk.ProxyRule, error) {
+	var rule beanstalk.ProxyRule
+	f := strings.Split(s, ":")
+	if len(f) != 3 {
+		return rule, fmt.Errorf("rule %q: want allow|deny:commands:tubes", s)
+	}
+	switch f[0] {
+	case "allow":
+		rule.Allow = true
+	case "deny":
+	default:
+		return rule, fmt.Errorf("rule %q: want allow or deny, got %q", s, f[0])
+	}
+	rule.Commands = list(f[1])
+	rule.Tubes = list(f[2])
+	return rule, nil
+}
+
+func list(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// logEntry is the JSON form of a beanstalk.ProxyEvent.
+type logEntry struct {
+	Time     time.Time `json:"time"`
+	Client   string    `json:"client"`
+	Command  string    `json:"command"`
+	Args     []string  `json:"args,omitempty"`
+	BodySize int       `json:"body_size,omitempty"`
+	Tube     string    `json:"tube,omitempty"`
+	Denied   bool      `json:"denied,omitempty"`
+	Response string    `json:"response"`
+	Error    string    `json:"error,omitempty"`
+	Millis   float64   `json:"ms"`
+}
+
+func logger(w io.Writer) func(beanstalk.ProxyEvent) {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(ev beanstalk.ProxyEvent) {
+		e := logEntry{
+			Time:     ev.Time,
+			Client:   ev.Client,
+			Command:  ev.Command,
+			Args:     ev.Args,
+			BodySize: ev.BodySize,
+			Tube:     ev.Tube,
+			Denied:   ev.Denied,
+			Response: ev.Response,
+			Millis:   float64(ev.Duration) / float64(time.Millisecond),
+		}
+		if ev.Err != nil {
+			e.Error = ev.Err.Error()
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(e)
+	}
+}
+
+func main() {
+	var p beanstalk.Proxy
+	addr := flag.String("addr", "127.0.0.1:11300", "server address")
+	listen := flag.String("listen", "127.0.0.1:11301", "address to accept clients on")
+	flag.IntVar(&p.MaxJobSize, "max-job-size", beanstalk.DefaultProxyMaxJobSize, "largest job body relayed, in bytes")
+	flag.BoolVar(&p.DenyByDefault, "deny-default", false, "deny commands matching no rule")
+	flag.Var((*rules)(&p.Rules), "rule", "allow|deny:commands:tubes (repeatable)")
+	flag.Parse()
+
+	p.Dial = func() (net.Conn, error) {
+		return net.DialTimeout("tcp", *addr, beanstalk.DefaultDialTimeout)
+	}
+	p.Log = logger(os.Stdout)
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "relaying %s to %s\n", l.Addr(), *addr)
+	fatal(p.Serve(l))
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "beanstalk-proxy:", err)
+	os.Exit(1)
+}
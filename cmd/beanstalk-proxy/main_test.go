@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+func TestParseRule(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want beanstalk.ProxyRule
+	}{
+		{"allow:delete:billing", beanstalk.ProxyRule{Allow: true, Commands: []string{"delete"}, Tubes: []string{"billing"}}},
+		{"deny:delete,kick:", beanstalk.ProxyRule{Commands: []string{"delete", "kick"}}},
+		{"deny::shared-*", beanstalk.ProxyRule{Tubes: []string{"shared-*"}}},
+	} {
+		got, err := parseRule(tt.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseRule(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+	for _, s := range []string{"deny", "permit:delete:", "allow:a:b:c"} {
+		if _, err := parseRule(s); err == nil {
+			t.Errorf("parseRule(%q) succeeded", s)
+		}
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var b bytes.Buffer
+	logger(&b)(beanstalk.ProxyEvent{
+		Time:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Client:   "10.0.0.1:5000",
+		Command:  "delete",
+		Args:     []string{"3"},
+		Tube:     "shared",
+		Response: "NOT_FOUND",
+		Err:      beanstalk.ErrNotFound,
+		Duration: 1500 * time.Microsecond,
+	})
+	want := `{"time":"2024-01-02T03:04:05Z","client":"10.0.0.1:5000","command":"delete","args":["3"],"tube":"shared","response":"NOT_FOUND","error":"not found","ms":1.5}` + "\n"
+	if b.String() != want {
+		t.Fatalf("got %s", b.String())
+	}
+}
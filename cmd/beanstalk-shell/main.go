@@ -0,0 +1,118 @@
+// Command beanstalk-shell is an interactive client for beanstalkd.
+//
+// Usage:
+//
+//	beanstalk-shell [-addr host:port] [-debug]
+//
+// It keeps one connection open for the whole session, so jobs
+// reserved in the shell can be released, buried, touched, or deleted
+// later in it. Type "help" at the prompt for the list of commands.
+// Tab completes command and tube names. With -debug, or after
+// "debug on", the raw protocol traffic is shown.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beanstalkd/go-beanstalk"
+	"github.com/beanstalkd/go-beanstalk/internal/term"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:11300", "server address")
+	debug := flag.Bool("debug", false, "show protocol traffic")
+	flag.Parse()
+
+	nc, err := net.DialTimeout("tcp", *addr, beanstalk.DefaultDialTimeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "beanstalk-shell:", err)
+		os.Exit(1)
+	}
+	wire := &wireLog{Conn: nc, w: os.Stderr, on: *debug}
+	sh := newShell(beanstalk.NewConn(wire), os.Stdout)
+	sh.wire = wire
+	defer sh.c.Close()
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		sc := bufio.NewScanner(os.Stdin)
+		for sc.Scan() {
+			if sh.exec(sc.Text()) {
+				return
+			}
+		}
+		return
+	}
+	ed := term.NewLineEditor(os.Stdin, os.Stdout, "beanstalk> ")
+	ed.Complete = sh.complete
+	for {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "beanstalk-shell:", err)
+			os.Exit(1)
+		}
+		line, err := ed.ReadLine()
+		term.Restore(fd, state)
+		if errors.Is(err, term.ErrInterrupt) {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if sh.exec(line) {
+			return
+		}
+	}
+}
+
+// wireLog shows the traffic on a connection when on is set.
+type wireLog struct {
+	net.Conn
+	w  io.Writer
+	on bool
+}
+
+func (l *wireLog) Read(b []byte) (int, error) {
+	n, err := l.Conn.Read(b)
+	if l.on && n > 0 {
+		logLines(l.w, "<<", b[:n])
+	}
+	return n, err
+}
+
+func (l *wireLog) Write(b []byte) (int, error) {
+	if l.on {
+		logLines(l.w, ">>", b)
+	}
+	return l.Conn.Write(b)
+}
+
+func logLines(w io.Writer, prefix string, b []byte) {
+	for _, s := range strings.SplitAfter(string(b), "\n") {
+		if s != "" {
+			q := fmt.Sprintf("%q", s)
+			fmt.Fprintf(w, "%s %s\n", prefix, q[1:len(q)-1])
+		}
+	}
+}
+
+// parseTimeout accepts a duration like "5s" or a plain number of seconds.
+func parseTimeout(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("bad duration %q", s)
+	}
+	return time.Duration(n) * time.Second, nil
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+type shell struct {
+	c       *beanstalk.Conn
+	out     io.Writer
+	used    *beanstalk.Tube
+	watched *beanstalk.TubeSet
+	wire    *wireLog
+}
+
+type command struct {
+	run   func(sh *shell, args []string) error
+	usage string
+	tubes bool // whether arguments are tube names, for completion
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"help":               {(*shell).help, "", false},
+		"quit":               {nil, "", false},
+		"use":                {(*shell).use, "tube", true},
+		"watch":              {(*shell).watch, "tube...", true},
+		"ignore":             {(*shell).ignore, "tube...", true},
+		"list-tubes":         {(*shell).listTubes, "", false},
+		"list-tube-used":     {(*shell).listTubeUsed, "", false},
+		"list-tubes-watched": {(*shell).listTubesWatched, "", false},
+		"put":                {(*shell).put, "[-pri n] [-delay d] [-ttr d] body...", false},
+		"reserve":            {(*shell).reserve, "[timeout]", false},
+		"reserve-job":        {(*shell).reserveJob, "id", false},
+		"peek":               {(*shell).peek, "id|ready|delayed|buried", false},
+		"delete":             {(*shell).delete, "id...", false},
+		"release":            {(*shell).release, "id [pri [delay]]", false},
+		"bury":               {(*shell).bury, "id [pri]", false},
+		"touch":              {(*shell).touch, "id", false},
+		"kick":               {(*shell).kick, "bound", false},
+		"kick-job":           {(*shell).kickJob, "id", false},
+		"stats":              {(*shell).stats, "", false},
+		"stats-tube":         {(*shell).statsTube, "[tube]", true},
+		"stats-job":          {(*shell).statsJob, "id", false},
+		"pause-tube":         {(*shell).pauseTube, "tube delay", true},
+		"debug":              {(*shell).debug, "on|off", false},
+	}
+}
+
+func newShell(c *beanstalk.Conn, out io.Writer) *shell {
+	return &shell{
+		c:       c,
+		out:     out,
+		used:    beanstalk.NewTube(c, "default"),
+		watched: beanstalk.NewTubeSet(c, "default"),
+	}
+}
+
+// exec runs one command line and reports whether the shell should exit.
+func (sh *shell) exec(line string) (quit bool) {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return false
+	}
+	name := args[0]
+	if name == "quit" || name == "exit" {
+		return true
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(sh.out, "unknown command %q; try help\n", name)
+		return false
+	}
+	if err := cmd.run(sh, args[1:]); err != nil {
+		fmt.Fprintln(sh.out, "error:", err)
+	}
+	return false
+}
+
+// complete returns completions of line: command names for the first
+// word, and tube names for the arguments of commands taking them.
+func (sh *shell) complete(line string) []string {
+	i := strings.LastIndexByte(line, ' ')
+	head, word := line[:i+1], line[i+1:]
+	var names []string
+	fields := strings.Fields(line)
+	if len(fields) == 0 || i == -1 {
+		for name := range commands {
+			names = append(names, name)
+		}
+	} else if cmd, ok := commands[fields[0]]; ok && cmd.tubes {
+		names, _ = sh.c.ListTubes()
+	}
+	var c []string
+	for _, name := range names {
+		if strings.HasPrefix(name, word) {
+			c = append(c, head+name)
+		}
+	}
+	sort.Strings(c)
+	if len(c) == 1 {
+		c[0] += " "
+	}
+	return c
+}
+
+func (sh *shell) help(args []string) error {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(sh.out, 0, 8, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%s\n", name, commands[name].usage)
+	}
+	return w.Flush()
+}
+
+func (sh *shell) use(args []string) error {
+	if len(args) != 1 {
+		return errUsage("use")
+	}
+	if err := beanstalk.CheckName(args[0]); err != nil {
+		return err
+	}
+	sh.used = beanstalk.NewTube(sh.c, args[0])
+	fmt.Fprintln(sh.out, "using", args[0])
+	return nil
+}
+
+func (sh *shell) watch(args []string) error {
+	if len(args) == 0 {
+		return errUsage("watch")
+	}
+	for _, name := range args {
+		if err := beanstalk.CheckName(name); err != nil {
+			return err
+		}
+	}
+	for _, name := range args {
+		sh.watched.Name[name] = true
+	}
+	fmt.Fprintln(sh.out, "watching", len(sh.watched.Name))
+	return nil
+}
+
+func (sh *shell) ignore(args []string) error {
+	if len(args) == 0 {
+		return errUsage("ignore")
+	}
+	left := 0
+	for name := range sh.watched.Name {
+		if !contains(args, name) {
+			left++
+		}
+	}
+	if left == 0 {
+		return errors.New("cannot ignore every watched tube")
+	}
+	for _, name := range args {
+		delete(sh.watched.Name, name)
+	}
+	fmt.Fprintln(sh.out, "watching", len(sh.watched.Name))
+	return nil
+}
+
+func (sh *shell) listTubes(args []string) error {
+	tubes, err := sh.c.ListTubes()
+	if err != nil {
+		return err
+	}
+	for _, name := range tubes {
+		fmt.Fprintln(sh.out, name)
+	}
+	return nil
+}
+
+func (sh *shell) listTubeUsed(args []string) error {
+	fmt.Fprintln(sh.out, sh.used.Name)
+	return nil
+}
+
+func (sh *shell) listTubesWatched(args []string) error {
+	var names []string
+	for name := range sh.watched.Name {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(sh.out, name)
+	}
+	return nil
+}
+
+func (sh *shell) put(args []string) error {
+	fs := flag.NewFlagSet("put", flag.ContinueOnError)
+	fs.SetOutput(sh.out)
+	pri := fs.String("pri", "0", "priority")
+	delay := fs.Duration("delay", 0, "delay")
+	ttr := fs.Duration("ttr", time.Minute, "time to run")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	p, err := strconv.ParseUint(*pri, 10, 32)
+	if err != nil {
+		return fmt.Errorf("bad priority %q", *pri)
+	}
+	body := strings.Join(fs.Args(), " ")
+	id, err := sh.used.Put([]byte(body), uint32(p), *delay, *ttr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, "inserted", id)
+	return nil
+}
+
+func (sh *shell) reserve(args []string) error {
+	var timeout time.Duration
+	if len(args) > 0 {
+		var err error
+		if timeout, err = parseTimeout(args[0]); err != nil {
+			return err
+		}
+	}
+	id, body, err := sh.watched.Reserve(timeout)
+	if err != nil {
+		return err
+	}
+	sh.printJob("reserved", id, body)
+	return nil
+}
+
+func (sh *shell) reserveJob(args []string) error {
+	id, err := oneID("reserve-job", args)
+	if err != nil {
+		return err
+	}
+	body, err := sh.c.ReserveJob(id)
+	if err != nil {
+		return err
+	}
+	sh.printJob("reserved", id, body)
+	return nil
+}
+
+func (sh *shell) peek(args []string) error {
+	if len(args) != 1 {
+		return errUsage("peek")
+	}
+	var id uint64
+	var body []byte
+	var err error
+	switch args[0] {
+	case "ready":
+		id, body, err = sh.used.PeekReady()
+	case "delayed":
+		id, body, err = sh.used.PeekDelayed()
+	case "buried":
+		id, body, err = sh.used.PeekBuried()
+	default:
+		if id, err = parseID(args[0]); err == nil {
+			body, err = sh.c.Peek(id)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	sh.printJob("found", id, body)
+	return nil
+}
+
+func (sh *shell) delete(args []string) error {
+	if len(args) == 0 {
+		return errUsage("delete")
+	}
+	for _, s := range args {
+		id, err := parseID(s)
+		if err != nil {
+			return err
+		}
+		if err = sh.c.Delete(id); err != nil {
+			return err
+		}
+		fmt.Fprintln(sh.out, "deleted", id)
+	}
+	return nil
+}
+
+func (sh *shell) release(args []string) error {
+	if len(args) < 1 || len(args) > 3 {
+		return errUsage("release")
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	pri, err := sh.priArg(id, args[1:])
+	if err != nil {
+		return err
+	}
+	var delay time.Duration
+	if len(args) > 2 {
+		if delay, err = parseTimeout(args[2]); err != nil {
+			return err
+		}
+	}
+	if err = sh.c.Release(id, pri, delay); err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, "released", id)
+	return nil
+}
+
+func (sh *shell) bury(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errUsage("bury")
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	pri, err := sh.priArg(id, args[1:])
+	if err != nil {
+		return err
+	}
+	if err = sh.c.Bury(id, pri); err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, "buried", id)
+	return nil
+}
+
+// priArg parses the optional priority in args, defaulting to the
+// job's current priority.
+func (sh *shell) priArg(id uint64, args []string) (uint32, error) {
+	s := ""
+	if len(args) > 0 {
+		s = args[0]
+	} else {
+		stats, err := sh.c.StatsJob(id)
+		if err != nil {
+			return 0, err
+		}
+		s = stats["pri"]
+	}
+	pri, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("bad priority %q", s)
+	}
+	return uint32(pri), nil
+}
+
+func (sh *shell) touch(args []string) error {
+	id, err := oneID("touch", args)
+	if err != nil {
+		return err
+	}
+	if err = sh.c.Touch(id); err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, "touched", id)
+	return nil
+}
+
+func (sh *shell) kick(args []string) error {
+	if len(args) != 1 {
+		return errUsage("kick")
+	}
+	bound, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("bad bound %q", args[0])
+	}
+	n, err := sh.used.Kick(bound)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, "kicked", n)
+	return nil
+}
+
+func (sh *shell) kickJob(args []string) error {
+	id, err := oneID("kick-job", args)
+	if err != nil {
+		return err
+	}
+	if err = sh.c.KickJob(id); err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, "kicked", id)
+	return nil
+}
+
+func (sh *shell) stats(args []string) error {
+	stats, err := sh.c.Stats()
+	if err != nil {
+		return err
+	}
+	return sh.printTable(stats)
+}
+
+func (sh *shell) statsTube(args []string) error {
+	t := sh.used
+	if len(args) > 0 {
+		t = beanstalk.NewTube(sh.c, args[0])
+	}
+	stats, err := t.Stats()
+	if err != nil {
+		return err
+	}
+	return sh.printTable(stats)
+}
+
+func (sh *shell) statsJob(args []string) error {
+	id, err := oneID("stats-job", args)
+	if err != nil {
+		return err
+	}
+	stats, err := sh.c.StatsJob(id)
+	if err != nil {
+		return err
+	}
+	return sh.printTable(stats)
+}
+
+func (sh *shell) pauseTube(args []string) error {
+	if len(args) != 2 {
+		return errUsage("pause-tube")
+	}
+	d, err := parseTimeout(args[1])
+	if err != nil {
+		return err
+	}
+	if err = beanstalk.NewTube(sh.c, args[0]).Pause(d); err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, "paused", args[0])
+	return nil
+}
+
+func (sh *shell) debug(args []string) error {
+	if len(args) != 1 || args[0] != "on" && args[0] != "off" {
+		return errUsage("debug")
+	}
+	if sh.wire == nil {
+		return errors.New("debugging is not available")
+	}
+	sh.wire.on = args[0] == "on"
+	return nil
+}
+
+func (sh *shell) printJob(verb string, id uint64, body []byte) {
+	fmt.Fprintf(sh.out, "%s %d (%d bytes)\n%s\n", verb, id, len(body), body)
+}
+
+// printTable prints a stats dictionary as an aligned two-column table.
+func (sh *shell) printTable(d map[string]string) error {
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	w := tabwriter.NewWriter(sh.out, 0, 8, 2, ' ', 0)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s\t%s\n", k, d[k])
+	}
+	return w.Flush()
+}
+
+func errUsage(name string) error {
+	return fmt.Errorf("usage: %s %s", name, commands[name].usage)
+}
+
+func oneID(name string, args []string) (uint64, error) {
+	if len(args) != 1 {
+		return 0, errUsage(name)
+	}
+	return parseID(args[0])
+}
+
+func parseID(s string) (uint64, error) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad job id %q", s)
+	}
+	return id, nil
+}
+
+func contains(a []string, s string) bool {
+	for _, t := range a {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+// fakeConn replies with a canned response and records what it is sent.
+type fakeConn struct {
+	io.Reader
+	sent *bytes.Buffer
+}
+
+func (c fakeConn) Write(b []byte) (int, error) { return c.sent.Write(b) }
+func (fakeConn) Close() error                  { return nil }
+
+func newTestShell(resp string) (*shell, *bytes.Buffer, *bytes.Buffer) {
+	var sent, out bytes.Buffer
+	c := beanstalk.NewConn(fakeConn{strings.NewReader(resp), &sent})
+	return newShell(c, &out), &sent, &out
+}
+
+func TestShellUsePut(t *testing.T) {
+	sh, sent, out := newTestShell("USING foo\r\nINSERTED 7\r\n")
+	sh.exec("use foo")
+	sh.exec("put -pri 3 hello world")
+	if exp := "use foo\r\nput 3 0 60 11\r\nhello world\r\n"; sent.String() != exp {
+		t.Fatalf("expected %#v, got %#v", exp, sent.String())
+	}
+	if exp := "using foo\ninserted 7\n"; out.String() != exp {
+		t.Fatalf("expected %#v, got %#v", exp, out.String())
+	}
+}
+
+func TestShellWatchReserve(t *testing.T) {
+	sh, sent, out := newTestShell("WATCHING 2\r\nWATCHING 1\r\nRESERVED 1 2\r\nhi\r\n")
+	sh.exec("watch foo")
+	sh.exec("ignore default")
+	sh.exec("ignore foo")
+	sh.exec("reserve 5")
+	if exp := "watch foo\r\nignore default\r\nreserve-with-timeout 5\r\n"; sent.String() != exp {
+		t.Fatalf("expected %#v, got %#v", exp, sent.String())
+	}
+	exp := "watching 2\nwatching 1\nerror: cannot ignore every watched tube\nreserved 1 (2 bytes)\nhi\n"
+	if out.String() != exp {
+		t.Fatalf("expected %#v, got %#v", exp, out.String())
+	}
+}
+
+func TestShellStatsTable(t *testing.T) {
+	sh, _, out := newTestShell("OK 31\r\n---\ncurrent-jobs-ready: 3\nid: 1\n\r\n")
+	sh.exec("stats-job 1")
+	if exp := "current-jobs-ready  3\nid                  1\n"; out.String() != exp {
+		t.Fatalf("expected %#v, got %#v", exp, out.String())
+	}
+}
+
+func TestShellComplete(t *testing.T) {
+	sh, _, _ := newTestShell("OK 23\r\n---\n- default\n- foobar\n\r\n")
+	if got := sh.complete("stats-t"); !reflect.DeepEqual(got, []string{"stats-tube "}) {
+		t.Fatalf("got %#v", got)
+	}
+	if got := sh.complete("use f"); !reflect.DeepEqual(got, []string{"use foobar "}) {
+		t.Fatalf("got %#v", got)
+	}
+}
+
+func TestShellCompleteBlank(t *testing.T) {
+	sh, _, _ := newTestShell("")
+	if got := sh.complete(" "); len(got) != len(commands) {
+		t.Fatalf("got %d completions, want %d", len(got), len(commands))
+	}
+	if got := sh.complete(" \t"); len(got) != 0 {
+		t.Fatalf("got %#v", got)
+	}
+}
+
+func TestShellBadName(t *testing.T) {
+	sh, sent, out := newTestShell("OK 4\r\n---\n\r\n")
+	sh.exec("use bad!")
+	sh.exec("watch foo bad!")
+	sh.exec("list-tubes")
+	if exp := "list-tubes\r\n"; sent.String() != exp {
+		t.Fatalf("expected %#v, got %#v", exp, sent.String())
+	}
+	if n := strings.Count(out.String(), "error: name has bad char: bad!"); n != 2 {
+		t.Fatalf("got %#v", out.String())
+	}
+}
+
+func TestShellBadPri(t *testing.T) {
+	sh, sent, out := newTestShell("")
+	sh.exec("put -pri 4294967296 hello")
+	if sent.Len() != 0 {
+		t.Fatalf("expected nothing sent, got %#v", sent.String())
+	}
+	if !strings.Contains(out.String(), `bad priority "4294967296"`) {
+		t.Fatalf("got %#v", out.String())
+	}
+}
+
+func TestLogLines(t *testing.T) {
+	var b bytes.Buffer
+	logLines(&b, ">>", []byte("use foo\r\nput 0 0 60 1\r\n"))
+	if exp := ">> use foo\\r\\n\n>> put 0 0 60 1\\r\\n\n"; b.String() != exp {
+		t.Fatalf("expected %#v, got %#v", exp, b.String())
+	}
+}
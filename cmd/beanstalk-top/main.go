@@ -0,0 +1,104 @@
+// Command beanstalk-top shows a continuously updated view of a
+// beanstalkd server and its tubes.
+//
+// Usage:
+//
+//	beanstalk-top [-addr host:port] [-interval d]
+//
+// Keys:
+//
+//	up, down   select a tube
+//	p          pause the selected tube for a minute, or unpause it
+//	K          kick the buried jobs in the selected tube, or its delayed
+//	           jobs if none are buried
+//	v          peek at the jobs in the selected tube
+//	q          quit
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/beanstalkd/go-beanstalk"
+	"github.com/beanstalkd/go-beanstalk/internal/term"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:11300", "server address")
+	interval := flag.Duration("interval", time.Second, "time between updates")
+	flag.Parse()
+
+	c, err := beanstalk.Dial("tcp", *addr)
+	if err != nil {
+		fatal(err)
+	}
+	defer c.Close()
+
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		fatal(err)
+	}
+	defer term.Restore(fd, state)
+	os.Stdout.WriteString("\x1b[?1049h\x1b[?25l")
+	defer os.Stdout.WriteString("\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan string)
+	go readKeys(bufio.NewReader(os.Stdin), keys)
+
+	v := &view{c: c}
+	tick := time.NewTicker(*interval)
+	defer tick.Stop()
+	for {
+		if err := v.update(); err != nil {
+			v.status = "error: " + err.Error()
+		}
+		w, h, err := term.Size(fd)
+		if err != nil {
+			w, h = 80, 24
+		}
+		v.render(os.Stdout, w, h)
+		select {
+		case <-tick.C:
+		case k := <-keys:
+			if k == "q" || k == "\x03" {
+				return
+			}
+			v.key(k)
+		}
+	}
+}
+
+// readKeys sends each key read from r on ch, with escape sequences
+// for arrow keys reduced to "up" and "down".
+func readKeys(r *bufio.Reader, ch chan<- string) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			close(ch)
+			return
+		}
+		k := string(b)
+		if b == 27 && r.Buffered() >= 2 {
+			seq := make([]byte, 2)
+			r.Read(seq)
+			switch string(seq) {
+			case "[A", "OA":
+				k = "up"
+			case "[B", "OB":
+				k = "down"
+			default:
+				continue
+			}
+		}
+		ch <- k
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "beanstalk-top:", err)
+	os.Exit(1)
+}
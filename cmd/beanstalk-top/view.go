@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+// A snapshot holds the statistics gathered in one update.
+type snapshot struct {
+	at     time.Time
+	server map[string]string
+	names  []string
+	tubes  map[string]map[string]string
+}
+
+type view struct {
+	c        *beanstalk.Conn
+	cur      *snapshot
+	prev     *snapshot
+	selected int
+	status   string
+	peek     []string
+}
+
+func (v *view) update() error {
+	s := &snapshot{at: time.Now(), tubes: make(map[string]map[string]string)}
+	var err error
+	if s.server, err = v.c.Stats(); err != nil {
+		return err
+	}
+	if s.names, err = v.c.ListTubes(); err != nil {
+		return err
+	}
+	for _, name := range s.names {
+		if s.tubes[name], err = beanstalk.NewTube(v.c, name).Stats(); err != nil {
+			return err
+		}
+	}
+	v.prev, v.cur = v.cur, s
+	if v.selected >= len(s.names) {
+		v.selected = len(s.names) - 1
+	}
+	if v.selected < 0 {
+		v.selected = 0
+	}
+	return nil
+}
+
+// rate returns the rate of change per second of the counter key
+// between the previous and current snapshots of d.
+func (v *view) rate(d func(*snapshot) map[string]string, key string) string {
+	if v.prev == nil || d(v.prev) == nil {
+		return "-"
+	}
+	secs := v.cur.at.Sub(v.prev.at).Seconds()
+	n := atoi(d(v.cur)[key]) - atoi(d(v.prev)[key])
+	if secs <= 0 || n < 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(float64(n)/secs, 'f', 1, 64)
+}
+
+func (v *view) key(k string) {
+	if v.cur == nil || len(v.cur.names) == 0 {
+		return
+	}
+	name := v.cur.names[v.selected]
+	t := beanstalk.NewTube(v.c, name)
+	v.peek = nil
+	switch k {
+	case "up", "k":
+		if v.selected > 0 {
+			v.selected--
+		}
+	case "down", "j":
+		if v.selected < len(v.cur.names)-1 {
+			v.selected++
+		}
+	case "p":
+		d := time.Minute
+		if atoi(v.cur.tubes[name]["pause-time-left"]) > 0 {
+			d = 0
+		}
+		if err := t.Pause(d); err != nil {
+			v.status = "pause: " + err.Error()
+		} else if d > 0 {
+			v.status = "paused " + name + " for " + d.String()
+		} else {
+			v.status = "unpaused " + name
+		}
+	case "K":
+		stats := v.cur.tubes[name]
+		bound := atoi(stats["current-jobs-buried"]) + atoi(stats["current-jobs-delayed"])
+		n, err := t.Kick(int(bound))
+		if err != nil {
+			v.status = "kick: " + err.Error()
+		} else {
+			v.status = fmt.Sprintf("kicked %d jobs in %s", n, name)
+		}
+	case "v":
+		for _, p := range []struct {
+			state string
+			peek  func() (uint64, []byte, error)
+		}{
+			{"ready", t.PeekReady},
+			{"delayed", t.PeekDelayed},
+			{"buried", t.PeekBuried},
+		} {
+			id, body, err := p.peek()
+			if err != nil {
+				v.peek = append(v.peek, fmt.Sprintf("%-8s %v", p.state, err))
+				continue
+			}
+			v.peek = append(v.peek, fmt.Sprintf("%-8s %d: %q", p.state, id, body))
+		}
+		v.status = "peeked at " + name
+	}
+}
+
+var tubeColumns = []struct {
+	title string
+	key   string
+}{
+	{"READY", "current-jobs-ready"},
+	{"URGENT", "current-jobs-urgent"},
+	{"RESERVED", "current-jobs-reserved"},
+	{"DELAYED", "current-jobs-delayed"},
+	{"BURIED", "current-jobs-buried"},
+	{"WAITING", "current-waiting"},
+	{"TOTAL", "total-jobs"},
+}
+
+// render draws the whole screen on w, which is width by height.
+func (v *view) render(w io.Writer, width, height int) {
+	var lines []string
+	add := func(format string, a ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, a...))
+	}
+	if s := v.cur; s != nil {
+		srv := func(s *snapshot) map[string]string { return s.server }
+		add("beanstalkd %s  pid %s  uptime %ss  connections %s  producers %s  workers %s  waiting %s",
+			s.server["version"], s.server["pid"], s.server["uptime"],
+			s.server["current-connections"], s.server["current-producers"],
+			s.server["current-workers"], s.server["current-waiting"])
+		add("jobs/s  put %s  reserve %s  delete %s  bury %s  kick %s",
+			v.rate(srv, "cmd-put"), v.rate(srv, "cmd-reserve"), v.rate(srv, "cmd-delete"),
+			v.rate(srv, "cmd-bury"), v.rate(srv, "cmd-kick"))
+		add("")
+		head := fmt.Sprintf("%-24s", "TUBE")
+		for _, col := range tubeColumns {
+			head += fmt.Sprintf(" %9s", col.title)
+		}
+		add("%s %8s %s", head, "PUT/s", "PAUSED")
+		for i, name := range s.names {
+			stats := s.tubes[name]
+			row := fmt.Sprintf("%-24s", name)
+			for _, col := range tubeColumns {
+				row += fmt.Sprintf(" %9s", stats[col.key])
+			}
+			tube := func(s *snapshot) map[string]string { return s.tubes[name] }
+			paused := ""
+			if left := atoi(stats["pause-time-left"]); left > 0 {
+				paused = fmt.Sprintf("%ds", left)
+			}
+			row = fmt.Sprintf("%s %8s %s", row, v.rate(tube, "total-jobs"), paused)
+			if i == v.selected {
+				row = "\x1b[7m" + pad(row, width) + "\x1b[0m"
+			}
+			lines = append(lines, row)
+		}
+	}
+	if len(v.peek) > 0 {
+		add("")
+		lines = append(lines, v.peek...)
+	}
+	if height < 2 {
+		height = 2
+	}
+	for len(lines) < height-1 {
+		add("")
+	}
+	lines = lines[:height-1]
+	add("%s", v.status)
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, l := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(truncate(l, width))
+		b.WriteString("\x1b[K")
+	}
+	io.WriteString(w, b.String())
+}
+
+func pad(s string, width int) string {
+	if len(s) < width {
+		s += strings.Repeat(" ", width-len(s))
+	}
+	return s
+}
+
+// truncate shortens s to width visible characters, ignoring the
+// escape sequences used for highlighting.
+func truncate(s string, width int) string {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\x1b' {
+			if j := strings.IndexByte(s[i:], 'm'); j != -1 {
+				i += j
+				continue
+			}
+		}
+		if n == width {
+			return s[:i] + "\x1b[0m"
+		}
+		n++
+	}
+	return s
+}
+
+func atoi(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+type fakeConn struct {
+	io.Reader
+}
+
+func (fakeConn) Write(b []byte) (int, error) { return len(b), nil }
+func (fakeConn) Close() error                { return nil }
+
+func yamlResp(body string) string {
+	return fmt.Sprintf("OK %d\r\n%s\r\n", len(body), body)
+}
+
+func TestViewUpdateRender(t *testing.T) {
+	resp := yamlResp("---\nversion: 1.12\ncmd-put: 10\n") +
+		yamlResp("---\n- default\n") +
+		yamlResp("---\ncurrent-jobs-ready: 4\ncurrent-jobs-buried: 2\ntotal-jobs: 10\npause-time-left: 0\n")
+	v := &view{c: beanstalk.NewConn(fakeConn{strings.NewReader(resp)})}
+	if err := v.update(); err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	v.render(&b, 200, 10)
+	out := b.String()
+	for _, s := range []string{"beanstalkd 1.12", "TUBE", "default", "\x1b[7m"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output missing %q:\n%s", s, out)
+		}
+	}
+	if n := strings.Count(out, "\r\n"); n != 9 {
+		t.Errorf("expected 10 lines, got %d", n+1)
+	}
+}
+
+func TestViewRate(t *testing.T) {
+	t0 := time.Now()
+	v := &view{
+		prev: &snapshot{at: t0, server: map[string]string{"cmd-put": "10"}},
+		cur:  &snapshot{at: t0.Add(2 * time.Second), server: map[string]string{"cmd-put": "15"}},
+	}
+	srv := func(s *snapshot) map[string]string { return s.server }
+	if got := v.rate(srv, "cmd-put"); got != "2.5" {
+		t.Fatalf("expected 2.5, got %s", got)
+	}
+	v.prev = nil
+	if got := v.rate(srv, "cmd-put"); got != "-" {
+		t.Fatalf("expected -, got %s", got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("hello", 3); got != "hel\x1b[0m" {
+		t.Fatalf("got %#v", got)
+	}
+	if got := truncate("\x1b[7mhello\x1b[0m", 10); got != "\x1b[7mhello\x1b[0m" {
+		t.Fatalf("got %#v", got)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+func init() {
+	commands["export"] = command{runExport, "[-drain] [-o file] [tube...]"}
+	commands["import"] = command{runImport, "[file]"}
+}
+
+func runExport(c *beanstalk.Conn, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	drain := fs.Bool("drain", false, "delete jobs after exporting them")
+	out := fs.String("o", "-", "output file")
+	fs.Parse(args)
+	tubes, err := tubesOrAll(c, fs.Args())
+	if err != nil {
+		return err
+	}
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	n, err := beanstalk.Export(w, c, tubes, *drain)
+	fmt.Fprintf(os.Stderr, "exported %d jobs\n", n)
+	return err
+}
+
+func runImport(c *beanstalk.Conn, args []string) error {
+	var r io.Reader = os.Stdin
+	if len(args) > 0 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	n, err := beanstalk.Import(r, c)
+	fmt.Fprintf(os.Stderr, "imported %d jobs\n", n)
+	return err
+}
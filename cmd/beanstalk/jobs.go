@@ -0,0 +1,203 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+func init() {
+	commands["put"] = command{runPut, "[-tube t] [-pri n] [-delay d] [-ttr d] [file]"}
+	commands["reserve"] = command{runReserve, "[-timeout d] [-delete] [tube...]"}
+	commands["peek"] = command{runPeek, "[-tube t] ready|delayed|buried|id"}
+	commands["delete"] = command{runDelete, "id..."}
+	commands["kick"] = command{runKick, "[-tube t] bound"}
+	commands["kick-job"] = command{runKickJob, "id"}
+	commands["bury"] = command{runBury, "[-pri n] id"}
+	commands["pause"] = command{runPause, "tube duration"}
+}
+
+type jobOutput struct {
+	ID   uint64 `json:"id"`
+	Body []byte `json:"body,omitempty"`
+}
+
+func printJob(id uint64, body []byte) {
+	output(jobOutput{id, body}, func() {
+		fmt.Fprintf(stdout, "id: %d\n%s\n", id, body)
+	})
+}
+
+func runPut(c *beanstalk.Conn, args []string) error {
+	fs := flag.NewFlagSet("put", flag.ExitOnError)
+	tube := fs.String("tube", "default", "tube to put the job into")
+	pri := fs.String("pri", "0", "priority")
+	delay := fs.Duration("delay", 0, "delay before the job is ready")
+	ttr := fs.Duration("ttr", time.Minute, "time to run")
+	fs.Parse(args)
+	p, err := parsePri(*pri)
+	if err != nil {
+		return err
+	}
+	var r io.Reader = os.Stdin
+	if fs.NArg() > 0 && fs.Arg(0) != "-" {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	id, err := beanstalk.NewTube(c, *tube).Put(body, p, *delay, *ttr)
+	if err != nil {
+		return err
+	}
+	output(jobOutput{ID: id}, func() { fmt.Fprintln(stdout, id) })
+	return nil
+}
+
+func runReserve(c *beanstalk.Conn, args []string) error {
+	fs := flag.NewFlagSet("reserve", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 0, "time to wait for a job")
+	del := fs.Bool("delete", false, "delete the job after printing it")
+	fs.Parse(args)
+	tubes := fs.Args()
+	if len(tubes) == 0 {
+		tubes = []string{"default"}
+	}
+	id, body, err := beanstalk.NewTubeSet(c, tubes...).Reserve(*timeout)
+	if err != nil {
+		return err
+	}
+	printJob(id, body)
+	if *del {
+		return c.Delete(id)
+	}
+	return nil
+}
+
+func runPeek(c *beanstalk.Conn, args []string) error {
+	fs := flag.NewFlagSet("peek", flag.ExitOnError)
+	tube := fs.String("tube", "default", "tube to peek into")
+	fs.Parse(args)
+	if err := needArgs("peek", fs.Args(), 1); err != nil {
+		return err
+	}
+	t := beanstalk.NewTube(c, *tube)
+	var id uint64
+	var body []byte
+	var err error
+	switch what := fs.Arg(0); what {
+	case "ready":
+		id, body, err = t.PeekReady()
+	case "delayed":
+		id, body, err = t.PeekDelayed()
+	case "buried":
+		id, body, err = t.PeekBuried()
+	default:
+		if id, err = parseID(what); err == nil {
+			body, err = c.Peek(id)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	printJob(id, body)
+	return nil
+}
+
+func runDelete(c *beanstalk.Conn, args []string) error {
+	if len(args) == 0 {
+		return needArgs("delete", args, 1)
+	}
+	for _, s := range args {
+		id, err := parseID(s)
+		if err != nil {
+			return err
+		}
+		if err = c.Delete(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runKick(c *beanstalk.Conn, args []string) error {
+	fs := flag.NewFlagSet("kick", flag.ExitOnError)
+	tube := fs.String("tube", "default", "tube to kick jobs in")
+	fs.Parse(args)
+	if err := needArgs("kick", fs.Args(), 1); err != nil {
+		return err
+	}
+	bound, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("bad bound %q", fs.Arg(0))
+	}
+	n, err := beanstalk.NewTube(c, *tube).Kick(bound)
+	if err != nil {
+		return err
+	}
+	output(map[string]int{"kicked": n}, func() { fmt.Fprintln(stdout, n) })
+	return nil
+}
+
+func runKickJob(c *beanstalk.Conn, args []string) error {
+	if err := needArgs("kick-job", args, 1); err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	return c.KickJob(id)
+}
+
+// runBury reserves the job by id, since only the connection holding
+// a reservation can bury it.
+func runBury(c *beanstalk.Conn, args []string) error {
+	fs := flag.NewFlagSet("bury", flag.ExitOnError)
+	pri := fs.String("pri", "", "new priority (default: keep the current one)")
+	fs.Parse(args)
+	if err := needArgs("bury", fs.Args(), 1); err != nil {
+		return err
+	}
+	id, err := parseID(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *pri == "" {
+		stats, err := c.StatsJob(id)
+		if err != nil {
+			return err
+		}
+		*pri = stats["pri"]
+	}
+	p, err := parsePri(*pri)
+	if err != nil {
+		return err
+	}
+	if _, err = c.ReserveJob(id); err != nil {
+		return err
+	}
+	return c.Bury(id, p)
+}
+
+func runPause(c *beanstalk.Conn, args []string) error {
+	if err := needArgs("pause", args, 2); err != nil {
+		return err
+	}
+	d, err := time.ParseDuration(args[1])
+	if err != nil {
+		return err
+	}
+	return beanstalk.NewTube(c, args[0]).Pause(d)
+}
@@ -0,0 +1,131 @@
+// Command beanstalk is a command-line client for beanstalkd.
+//
+// Usage:
+//
+//	beanstalk [-addr host:port] [-json] command [arguments]
+//
+// Run "beanstalk help" for the list of commands.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+type command struct {
+	run   func(c *beanstalk.Conn, args []string) error
+	usage string
+}
+
+var commands = map[string]command{}
+
+var (
+	jsonOut           = flag.Bool("json", false, "print results as JSON")
+	stdout  io.Writer = os.Stdout
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:11300", "server address")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 || flag.Arg(0) == "help" {
+		usage()
+		return
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "beanstalk: unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+	c, err := beanstalk.Dial("tcp", *addr)
+	if err != nil {
+		fatal(err)
+	}
+	err = cmd.run(c, flag.Args()[1:])
+	c.Close()
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: beanstalk [-addr host:port] [-json] command [arguments]\n\ncommands:\n")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s %s\n", name, commands[name].usage)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "beanstalk:", err)
+	os.Exit(1)
+}
+
+// tubesOrAll returns names, or all tubes on the server if names is empty.
+func tubesOrAll(c *beanstalk.Conn, names []string) ([]string, error) {
+	if len(names) > 0 {
+		return names, nil
+	}
+	return c.ListTubes()
+}
+
+// output prints v as JSON if -json was given, or else calls human.
+func output(v interface{}, human func()) {
+	if !*jsonOut {
+		human()
+		return
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Fprintf(stdout, "%s\n", b)
+}
+
+// printDict prints the entries of d sorted by key.
+func printDict(d map[string]string) {
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(stdout, "%s: %s\n", k, d[k])
+	}
+}
+
+func parseID(s string) (uint64, error) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad job id %q", s)
+	}
+	return id, nil
+}
+
+func parsePri(s string) (uint32, error) {
+	pri, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("bad priority %q", s)
+	}
+	return uint32(pri), nil
+}
+
+// needArgs returns an error unless args has exactly n elements.
+func needArgs(name string, args []string, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("usage: %s %s", name, commands[name].usage)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+// fakeConn replies with a canned response and discards what it is sent.
+type fakeConn struct {
+	io.Reader
+}
+
+func (fakeConn) Write(b []byte) (int, error) { return len(b), nil }
+func (fakeConn) Close() error                { return nil }
+
+func run(t *testing.T, name string, args []string, json bool, resp string) string {
+	var b bytes.Buffer
+	stdout = &b
+	*jsonOut = json
+	defer func() { *jsonOut = false }()
+	c := beanstalk.NewConn(fakeConn{strings.NewReader(resp)})
+	if err := commands[name].run(c, args); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestListTubes(t *testing.T) {
+	resp := "OK 20\r\n---\n- default\n- foo\n\r\n"
+	if got := run(t, "list-tubes", nil, false, resp); got != "default\nfoo\n" {
+		t.Fatalf("got %#v", got)
+	}
+	if got := run(t, "list-tubes", nil, true, resp); got != "[\"default\",\"foo\"]\n" {
+		t.Fatalf("got %#v", got)
+	}
+}
+
+func TestStatsJob(t *testing.T) {
+	resp := "OK 23\r\n---\nstate: ready\nid: 1\n\r\n"
+	if got := run(t, "stats-job", []string{"1"}, false, resp); got != "id: 1\nstate: ready\n" {
+		t.Fatalf("got %#v", got)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	resp := "FOUND 3 2\r\nhi\r\n"
+	if got := run(t, "peek", []string{"3"}, false, resp); got != "id: 3\nhi\n" {
+		t.Fatalf("got %#v", got)
+	}
+	if got := run(t, "peek", []string{"3"}, true, resp); got != "{\"id\":3,\"body\":\"aGk=\"}\n" {
+		t.Fatalf("got %#v", got)
+	}
+}
+
+func TestBadPri(t *testing.T) {
+	for _, args := range [][]string{
+		{"put", "-pri", "4294967296", "-"},
+		{"bury", "-pri", "4294967296", "1"},
+		{"bury", "-pri", "-1", "1"},
+	} {
+		c := beanstalk.NewConn(fakeConn{strings.NewReader("")})
+		err := commands[args[0]].run(c, args[1:])
+		if err == nil || !strings.Contains(err.Error(), "bad priority") {
+			t.Fatalf("%v: expected bad priority, got %v", args, err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+func init() {
+	commands["migrate"] = command{runMigrate, "-to host:port [-interval d] [-dry-run] [-journal file] [tube...]"}
+}
+
+func runMigrate(c *beanstalk.Conn, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	to := fs.String("to", "", "destination server address")
+	interval := fs.Duration("interval", 0, "minimum time between moving jobs")
+	dryRun := fs.Bool("dry-run", false, "count jobs without moving them")
+	journal := fs.String("journal", "", "file recording progress, for resuming")
+	fs.Parse(args)
+	if *to == "" {
+		return fmt.Errorf("migrate: -to is required")
+	}
+	tubes, err := tubesOrAll(c, fs.Args())
+	if err != nil {
+		return err
+	}
+	dst, err := beanstalk.Dial("tcp", *to)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	m := &beanstalk.Migration{
+		Src:      c,
+		Dst:      dst,
+		Tubes:    tubes,
+		Interval: *interval,
+		DryRun:   *dryRun,
+		Journal:  *journal,
+	}
+	report, err := m.Run(ctx)
+	printReport(report, *dryRun)
+	return err
+}
+
+func printReport(r *beanstalk.MigrationReport, dryRun bool) {
+	output(r, func() {
+		verb := "moved"
+		if dryRun {
+			verb = "would move"
+		}
+		var tubes []string
+		for name := range r.Moved {
+			tubes = append(tubes, name)
+		}
+		sort.Strings(tubes)
+		total := 0
+		for _, name := range tubes {
+			fmt.Fprintf(stdout, "%s %d jobs from %s\n", verb, r.Moved[name], name)
+			total += r.Moved[name]
+		}
+		fmt.Fprintf(stdout, "%s %d jobs in total\n", verb, total)
+		if r.Resumed > 0 {
+			fmt.Fprintf(stdout, "finished %d interrupted moves\n", r.Resumed)
+		}
+		for _, id := range r.Uncertain {
+			fmt.Fprintf(stdout, "job %d may exist on both servers\n", id)
+		}
+	})
+}
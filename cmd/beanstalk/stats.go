@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+func init() {
+	commands["stats"] = command{runStats, ""}
+	commands["stats-tube"] = command{runStatsTube, "[tube...]"}
+	commands["stats-job"] = command{runStatsJob, "id"}
+	commands["list-tubes"] = command{runListTubes, ""}
+}
+
+func runStats(c *beanstalk.Conn, args []string) error {
+	stats, err := c.Stats()
+	if err != nil {
+		return err
+	}
+	output(stats, func() { printDict(stats) })
+	return nil
+}
+
+func runStatsTube(c *beanstalk.Conn, args []string) error {
+	tubes, err := tubesOrAll(c, args)
+	if err != nil {
+		return err
+	}
+	all := make(map[string]map[string]string)
+	for _, name := range tubes {
+		if all[name], err = beanstalk.NewTube(c, name).Stats(); err != nil {
+			return err
+		}
+	}
+	output(all, func() {
+		for i, name := range tubes {
+			if i > 0 {
+				fmt.Fprintln(stdout)
+			}
+			printDict(all[name])
+		}
+	})
+	return nil
+}
+
+func runStatsJob(c *beanstalk.Conn, args []string) error {
+	if err := needArgs("stats-job", args, 1); err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	stats, err := c.StatsJob(id)
+	if err != nil {
+		return err
+	}
+	output(stats, func() { printDict(stats) })
+	return nil
+}
+
+func runListTubes(c *beanstalk.Conn, args []string) error {
+	tubes, err := c.ListTubes()
+	if err != nil {
+		return err
+	}
+	output(tubes, func() {
+		for _, name := range tubes {
+			fmt.Fprintln(stdout, name)
+		}
+	})
+	return nil
+}
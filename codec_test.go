@@ -0,0 +1,52 @@
+package beanstalk
+
+import (
+	"reflect"
+	"testing"
+)
+
+type codecTestValue struct {
+	A string
+	B int
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec{}, GobCodec{}} {
+		in := codecTestValue{"x", 1}
+		b, err := codec.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out codecTestValue
+		if err = codec.Unmarshal(b, &out); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Fatalf("%T: expected %#v, got %#v", codec, in, out)
+		}
+	}
+}
+
+func TestRawCodec(t *testing.T) {
+	b, err := RawCodec{}.Marshal("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s []byte
+	if err = (RawCodec{}).Unmarshal(b, &s); err != nil {
+		t.Fatal(err)
+	}
+	if string(s) != "foo" {
+		t.Fatalf("expected %#v, got %#v", "foo", string(s))
+	}
+}
+
+func TestRawCodecBadType(t *testing.T) {
+	if _, err := (RawCodec{}).Marshal(1); err == nil {
+		t.Fatal("expected error")
+	}
+	var n int
+	if err := (RawCodec{}).Unmarshal([]byte("1"), &n); err == nil {
+		t.Fatal("expected error")
+	}
+}
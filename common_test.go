@@ -63,3 +63,27 @@ func (m mockIO) Close() error {
 	m.send.Read(b)
 	return mockError{b, nil}
 }
+
+// recordingIO is like mockIO, but accepts any input and appends it
+// to a string for inspection.
+type recordingIO struct {
+	recv *string
+	send *strings.Reader
+}
+
+func recordIO(recv *string, send string) io.ReadWriteCloser {
+	return &recordingIO{recv, strings.NewReader(send)}
+}
+
+func (m recordingIO) Read(b []byte) (int, error) {
+	return m.send.Read(b)
+}
+
+func (m recordingIO) Write(b []byte) (int, error) {
+	*m.recv += string(b)
+	return len(b), nil
+}
+
+func (m recordingIO) Close() error {
+	return nil
+}
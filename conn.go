@@ -80,13 +80,15 @@ func (c *Conn) cmd(t *Tube, ts *TubeSet, body []byte, op string, args ...interfa
 		}
 	}
 
-	r := req{c.c.Next(), op}
-	c.c.StartRequest(r.id)
-	defer c.c.EndRequest(r.id)
-	err := c.adjustTubes(t, ts)
-	if err != nil {
+	// Names are checked before the request is sequenced, since a
+	// request that is never answered would hold up every later one.
+	if err := checkTubes(t, ts); err != nil {
 		return req{}, err
 	}
+
+	r := req{c.c.Next(), op}
+	c.c.StartRequest(r.id)
+	c.adjustTubes(t, ts)
 	if body != nil {
 		args = append(args, len(body))
 	}
@@ -95,27 +97,41 @@ func (c *Conn) cmd(t *Tube, ts *TubeSet, body []byte, op string, args ...interfa
 		c.c.W.Write(body)
 		c.c.W.Write(crnl)
 	}
-	err = c.c.W.Flush()
+	err := c.c.W.Flush()
+	c.c.EndRequest(r.id)
 	if err != nil {
+		// No response will be read; give up its turn.
+		c.c.StartResponse(r.id)
+		c.c.EndResponse(r.id)
 		return req{}, ConnError{c, op, err}
 	}
 	return r, nil
 }
 
-func (c *Conn) adjustTubes(t *Tube, ts *TubeSet) error {
-	if t != nil && t.Name != c.used {
+func checkTubes(t *Tube, ts *TubeSet) error {
+	if t != nil {
 		if err := checkName(t.Name); err != nil {
 			return err
 		}
+	}
+	if ts != nil {
+		for s := range ts.Name {
+			if err := checkName(s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Conn) adjustTubes(t *Tube, ts *TubeSet) {
+	if t != nil && t.Name != c.used {
 		c.printLine("use", t.Name)
 		c.used = t.Name
 	}
 	if ts != nil {
 		for s := range ts.Name {
 			if !c.watched[s] {
-				if err := checkName(s); err != nil {
-					return err
-				}
 				c.printLine("watch", s)
 			}
 		}
@@ -129,7 +145,6 @@ func (c *Conn) adjustTubes(t *Tube, ts *TubeSet) error {
 			c.watched[s] = true
 		}
 	}
-	return nil
 }
 
 // does not flush
@@ -197,6 +212,16 @@ func (c *Conn) Release(id uint64, pri uint32, delay time.Duration) error {
 	return err
 }
 
+// ReleaseAt releases the given job as in Release, delaying it until
+// time at. It returns ErrDelayTooLong if at is beyond MaxDelay from now.
+func (c *Conn) ReleaseAt(id uint64, pri uint32, at time.Time) error {
+	delay, err := delayUntil(at)
+	if err != nil {
+		return err
+	}
+	return c.Release(id, pri, delay)
+}
+
 // Bury places the given job in a holding area in the job's tube and
 // sets its priority to pri. The job will not be scheduled again until it
 // has been kicked; see also the documentation of Kick.
@@ -270,6 +295,25 @@ func (c *Conn) StatsJob(id uint64) (map[string]string, error) {
 	return parseDict(body), err
 }
 
+// statsJob retrieves and parses statistics about the given job.
+func (c *Conn) statsJob(id uint64) (jobInfo, error) {
+	stats, err := c.StatsJob(id)
+	if err != nil {
+		return jobInfo{}, err
+	}
+	info, err := parseJobInfo(stats)
+	if err != nil {
+		return jobInfo{}, ConnError{c, "stats-job", err}
+	}
+	return info, nil
+}
+
+// jobPri returns the current priority of the given job.
+func (c *Conn) jobPri(id uint64) (uint32, error) {
+	info, err := c.statsJob(id)
+	return info.pri, err
+}
+
 // ListTubes returns the names of the tubes that currently
 // exist on the server.
 func (c *Conn) ListTubes() ([]string, error) {
@@ -44,6 +44,32 @@ func TestNameBadChar(t *testing.T) {
 	}
 }
 
+func TestBadNameThenCommand(t *testing.T) {
+	c := NewConn(mock("stats\r\n", "OK 4\r\n---\n\r\n"))
+	if _, err := NewTube(c, "bad!").Put([]byte("x"), 0, 0, 0); err == nil {
+		t.Fatal("expected NameError")
+	}
+	if _, _, err := NewTubeSet(c, "foo", "bad!").Reserve(0); err == nil {
+		t.Fatal("expected NameError")
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Stats()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("command after a bad name hung")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestNegativeDuration(t *testing.T) {
 	c := NewConn(mock("", ""))
 	tube := NewTube(c, "foo")
@@ -265,3 +291,20 @@ func TestTouch(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestReleaseAt(t *testing.T) {
+	t0 := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	setNow(t, t0)
+	c := NewConn(mock("release 1 3 60\r\n", "RELEASED\r\n"))
+
+	err := c.ReleaseAt(1, 3, t0.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.ReleaseAt(1, 3, t0.Add(2*MaxDelay)); err != ErrDelayTooLong {
+		t.Fatal("expected ErrDelayTooLong, got", err)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
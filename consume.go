@@ -0,0 +1,85 @@
+package beanstalk
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultConsumeTimeout is the reserve timeout used by Consume when
+// ConsumeOptions.Timeout is zero.
+const DefaultConsumeTimeout = time.Second
+
+// deadlineBackoff is how long to wait before reserving again after
+// DEADLINE_SOON, which the server returns immediately for as long as a
+// job reserved on the connection is near the end of its TTR.
+var deadlineBackoff = 250 * time.Millisecond
+
+// Job is a job reserved from the server.
+type Job struct {
+	ID   uint64
+	Body []byte
+}
+
+// ConsumeOptions controls the behavior of Consume.
+type ConsumeOptions struct {
+	// Timeout is passed to Reserve on each iteration of the reserve
+	// loop. It bounds how long Consume takes to notice that its context
+	// is done. If zero, DefaultConsumeTimeout is used.
+	Timeout time.Duration
+
+	// Prefetch is the number of reserved jobs buffered in the channel
+	// waiting to be received. One more job is held by the reserve loop
+	// while the buffer is full, so up to Prefetch+1 jobs wait reserved,
+	// with their TTRs running, and even a Prefetch of zero holds one.
+	Prefetch int
+
+	// OnError, if non-nil, is called with the error that ended the
+	// reserve loop.
+	OnError func(error)
+}
+
+// Consume reserves jobs from the tubes in t in a background goroutine
+// and delivers them on the returned channel. Timeouts and DEADLINE_SOON
+// responses are handled internally. Any other error ends the loop; it is
+// passed to opts.OnError and the channel is closed. The channel is also
+// closed once ctx is done.
+//
+// A job reserved but not yet delivered when ctx is done is left
+// reserved; the server will return it to the ready queue when its TTR
+// expires. The caller is responsible for deleting, releasing, or burying
+// each job it receives.
+func (t *TubeSet) Consume(ctx context.Context, opts ConsumeOptions) <-chan Job {
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultConsumeTimeout
+	}
+	ch := make(chan Job, opts.Prefetch)
+	go func() {
+		defer close(ch)
+		for ctx.Err() == nil {
+			id, body, err := t.Reserve(opts.Timeout)
+			if errors.Is(err, ErrTimeout) {
+				continue
+			}
+			if errors.Is(err, ErrDeadline) {
+				select {
+				case <-time.After(deadlineBackoff):
+				case <-ctx.Done():
+				}
+				continue
+			}
+			if err != nil {
+				if opts.OnError != nil {
+					opts.OnError(err)
+				}
+				return
+			}
+			select {
+			case ch <- Job{id, body}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
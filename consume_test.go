@@ -0,0 +1,67 @@
+package beanstalk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestConsume(t *testing.T) {
+	c := NewConn(mock(
+		"reserve-with-timeout 1\r\nreserve-with-timeout 1\r\nreserve-with-timeout 1\r\n",
+		"TIMED_OUT\r\nRESERVED 1 1\r\nx\r\n",
+	))
+	var gotErr error
+	ch := c.Consume(context.Background(), ConsumeOptions{
+		OnError: func(err error) { gotErr = err },
+	})
+	j, ok := <-ch
+	if !ok {
+		t.Fatal("channel closed early")
+	}
+	if j.ID != 1 || string(j.Body) != "x" {
+		t.Fatalf("expected job 1 %#v, got %d %#v", "x", j.ID, string(j.Body))
+	}
+	if _, ok = <-ch; ok {
+		t.Fatal("expected channel to be closed")
+	}
+	if !errors.Is(gotErr, io.EOF) {
+		t.Fatal("expected EOF, got", gotErr)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConsumeCanceled(t *testing.T) {
+	c := NewConn(mock("", ""))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := <-c.Consume(ctx, ConsumeOptions{}); ok {
+		t.Fatal("expected channel to be closed")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConsumeDeadline(t *testing.T) {
+	deadlineBackoff = 50 * time.Millisecond
+	defer func() { deadlineBackoff = 250 * time.Millisecond }()
+	c := NewConn(mock(
+		"reserve-with-timeout 1\r\nreserve-with-timeout 1\r\nreserve-with-timeout 1\r\n",
+		"DEADLINE_SOON\r\nDEADLINE_SOON\r\nRESERVED 1 1\r\nx\r\n",
+	))
+	start := time.Now()
+	ch := c.Consume(context.Background(), ConsumeOptions{})
+	if j := <-ch; j.ID != 1 {
+		t.Fatal("expected job 1, got", j.ID)
+	}
+	if d := time.Since(start); d < 2*deadlineBackoff {
+		t.Fatalf("reserved again after DEADLINE_SOON without waiting (%v)", d)
+	}
+	for range ch {
+	}
+}
@@ -0,0 +1,51 @@
+package beanstalk
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	t0 := time.Date(2021, 1, 1, 12, 30, 15, 0, time.UTC) // a Friday
+	for _, tt := range []struct {
+		spec string
+		exp  time.Time
+	}{
+		{"* * * * *", time.Date(2021, 1, 1, 12, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 1, 1, 12, 45, 0, 0, time.UTC)},
+		{"0 9-17 * * *", time.Date(2021, 1, 1, 13, 0, 0, 0, time.UTC)},
+		{"5 0 * * *", time.Date(2021, 1, 2, 0, 5, 0, 0, time.UTC)},
+		{"0 0 * * mon", time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2021, 1, 8, 0, 0, 0, 0, time.UTC)},
+		{"30 12 1,15 * *", time.Date(2021, 1, 15, 12, 30, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		s, err := ParseSchedule(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Next(t0); !got.Equal(tt.exp) {
+			t.Errorf("%q: expected %v, got %v", tt.spec, tt.exp, got)
+		}
+	}
+}
+
+func TestParseScheduleBad(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+	} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package beanstalk
+
+import (
+	"bytes"
+	"errors"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDeadLetterSuffix is appended to a tube's name to name its
+// dead-letter tube when DeadLetter.Suffix is empty.
+const DefaultDeadLetterSuffix = ".dlq"
+
+// Header names recorded in dead-letter envelopes.
+const (
+	HeaderDLQOrigin    = "Dlq-Origin"     // name of the original tube
+	HeaderDLQReason    = "Dlq-Reason"     // failure reason given to Fail
+	HeaderDLQAttempts  = "Dlq-Attempts"   // number of times the job was reserved
+	HeaderDLQCreatedAt = "Dlq-Created-At" // RFC 3339
+	HeaderDLQFailedAt  = "Dlq-Failed-At"  // RFC 3339
+	HeaderDLQPlain     = "Dlq-Plain"      // "1" if the original body had no envelope
+)
+
+var dlqHeaders = []string{
+	HeaderDLQOrigin,
+	HeaderDLQReason,
+	HeaderDLQAttempts,
+	HeaderDLQCreatedAt,
+	HeaderDLQFailedAt,
+	HeaderDLQPlain,
+}
+
+// DeadLetter moves failed jobs into a dead-letter tube, named by
+// adding Suffix to the name of the job's tube, instead of burying
+// them. The failure context is recorded in an Envelope around the
+// original body, so it can be inspected with ReserveEnvelope or
+// ParseEnvelope, and Replay can move the job back.
+type DeadLetter struct {
+	Conn *Conn
+
+	// Suffix names the dead-letter tube of each tube.
+	// If empty, DefaultDeadLetterSuffix is used.
+	Suffix string
+}
+
+func (d *DeadLetter) suffix() string {
+	if d.Suffix == "" {
+		return DefaultDeadLetterSuffix
+	}
+	return d.Suffix
+}
+
+// Fail moves the given reserved job, whose body is body, into the
+// dead-letter tube of its tube, recording reason, then deletes the
+// original. The dead-letter job keeps the original priority and TTR.
+// A body that cannot be parsed as an envelope is kept as it is, as
+// the payload of a new one.
+func (d *DeadLetter) Fail(id uint64, body []byte, reason string) error {
+	info, err := d.Conn.statsJob(id)
+	if err != nil {
+		return err
+	}
+	e, err := ParseEnvelope(body)
+	if err != nil || !bytes.HasPrefix(body, envelopeMagic) {
+		e = &Envelope{textproto.MIMEHeader{}, body}
+		e.Header.Set(HeaderDLQPlain, "1")
+	}
+	h := e.Header
+	t := now().UTC()
+	h.Set(HeaderDLQOrigin, info.tube)
+	h.Set(HeaderDLQReason, strings.NewReplacer("\r", " ", "\n", " ").Replace(reason))
+	h.Set(HeaderDLQAttempts, strconv.Itoa(info.reserves))
+	h.Set(HeaderDLQCreatedAt, t.Add(-info.age).Format(time.RFC3339))
+	h.Set(HeaderDLQFailedAt, t.Format(time.RFC3339Nano))
+	dlq := NewTube(d.Conn, info.tube+d.suffix())
+	if _, err = dlq.PutEnvelope(e, info.pri, 0, info.ttr); err != nil {
+		return err
+	}
+	return d.Conn.Delete(id)
+}
+
+// Replay moves up to max jobs from the dead-letter tube of the named
+// tube back to the tubes they came from, removing the dead-letter
+// headers, and returns the number of jobs moved. If max is zero or
+// negative, Replay moves jobs until the dead-letter tube has none
+// ready. Jobs whose bodies cannot be parsed as envelopes are moved to
+// the named tube unchanged.
+func (d *DeadLetter) Replay(tube string, max int) (n int, err error) {
+	dlq := NewTubeSet(d.Conn, tube+d.suffix())
+	for max <= 0 || n < max {
+		id, body, err := dlq.Reserve(0)
+		if errors.Is(err, ErrTimeout) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		info, err := d.Conn.statsJob(id)
+		if err != nil {
+			return n, err
+		}
+		e, err := ParseEnvelope(body)
+		if err != nil {
+			e = &Envelope{textproto.MIMEHeader{HeaderDLQPlain: {"1"}}, body}
+		}
+		origin := e.Header.Get(HeaderDLQOrigin)
+		if origin == "" {
+			origin = tube
+		}
+		plain := e.Header.Get(HeaderDLQPlain) == "1"
+		for _, k := range dlqHeaders {
+			e.Header.Del(k)
+		}
+		t := NewTube(d.Conn, origin)
+		if plain {
+			_, err = t.Put(e.Body, info.pri, 0, info.ttr)
+		} else {
+			_, err = t.PutEnvelope(e, info.pri, 0, info.ttr)
+		}
+		if err != nil {
+			return n, err
+		}
+		if err = d.Conn.Delete(id); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
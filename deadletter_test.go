@@ -0,0 +1,121 @@
+package beanstalk
+
+import (
+	"fmt"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+const dlqStats = "---\ntube: foo\npri: 3\nage: 60\nttr: 10\nreserves: 2\n"
+
+func TestDeadLetterFail(t *testing.T) {
+	t0 := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	setNow(t, t0)
+	e := &Envelope{textproto.MIMEHeader{
+		HeaderDLQOrigin:    {"foo"},
+		HeaderDLQReason:    {"bad input"},
+		HeaderDLQAttempts:  {"2"},
+		HeaderDLQCreatedAt: {"1999-12-31T23:59:00Z"},
+		HeaderDLQFailedAt:  {"2000-01-01T00:00:00Z"},
+		HeaderDLQPlain:     {"1"},
+		HeaderEnqueuedAt:   {"2000-01-01T00:00:00Z"},
+	}, []byte("x")}
+	b, err := e.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewConn(mock(
+		fmt.Sprintf("stats-job 1\r\nuse foo.dlq\r\nput 3 0 10 %d\r\n%s\r\ndelete 1\r\n", len(b), b),
+		fmt.Sprintf("OK %d\r\n%s\r\nUSING foo.dlq\r\nINSERTED 2\r\nDELETED\r\n", len(dlqStats), dlqStats),
+	))
+	d := &DeadLetter{Conn: c}
+	if err = d.Fail(1, []byte("x"), "bad\ninput"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeadLetterFailBadEnvelope(t *testing.T) {
+	t0 := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	setNow(t, t0)
+	body := []byte("\x00BSE\x01no header end")
+	e := &Envelope{textproto.MIMEHeader{
+		HeaderDLQOrigin:    {"foo"},
+		HeaderDLQReason:    {"bad"},
+		HeaderDLQAttempts:  {"2"},
+		HeaderDLQCreatedAt: {"1999-12-31T23:59:00Z"},
+		HeaderDLQFailedAt:  {"2000-01-01T00:00:00Z"},
+		HeaderDLQPlain:     {"1"},
+		HeaderEnqueuedAt:   {"2000-01-01T00:00:00Z"},
+	}, body}
+	b, err := e.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewConn(mock(
+		fmt.Sprintf("stats-job 1\r\nuse foo.dlq\r\nput 3 0 10 %d\r\n%s\r\ndelete 1\r\n", len(b), b),
+		fmt.Sprintf("OK %d\r\n%s\r\nUSING foo.dlq\r\nINSERTED 2\r\nDELETED\r\n", len(dlqStats), dlqStats),
+	))
+	d := &DeadLetter{Conn: c}
+	if err = d.Fail(1, body, "bad"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeadLetterReplay(t *testing.T) {
+	e := &Envelope{textproto.MIMEHeader{
+		HeaderDLQOrigin: {"foo"},
+		HeaderDLQReason: {"bad input"},
+		HeaderDLQPlain:  {"1"},
+	}, []byte("x")}
+	b, err := e.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewConn(mock(
+		"watch foo.dlq\r\nignore default\r\nreserve-with-timeout 0\r\nstats-job 2\r\n"+
+			"use foo\r\nput 3 0 10 1\r\nx\r\ndelete 2\r\nreserve-with-timeout 0\r\n",
+		fmt.Sprintf("WATCHING 2\r\nWATCHING 1\r\nRESERVED 2 %d\r\n%s\r\n", len(b), b)+
+			fmt.Sprintf("OK %d\r\n%s\r\n", len(dlqStats), dlqStats)+
+			"USING foo\r\nINSERTED 3\r\nDELETED\r\nTIMED_OUT\r\n",
+	))
+	d := &DeadLetter{Conn: c}
+	n, err := d.Replay("foo", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("expected 1, got", n)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeadLetterReplayBadEnvelope(t *testing.T) {
+	b := "\x00BSE\x01no header end"
+	c := NewConn(mock(
+		"watch foo.dlq\r\nignore default\r\nreserve-with-timeout 0\r\nstats-job 2\r\n"+
+			fmt.Sprintf("use foo\r\nput 3 0 10 %d\r\n%s\r\ndelete 2\r\nreserve-with-timeout 0\r\n", len(b), b),
+		fmt.Sprintf("WATCHING 2\r\nWATCHING 1\r\nRESERVED 2 %d\r\n%s\r\n", len(b), b)+
+			fmt.Sprintf("OK %d\r\n%s\r\n", len(dlqStats), dlqStats)+
+			"USING foo\r\nINSERTED 3\r\nDELETED\r\nTIMED_OUT\r\n",
+	))
+	d := &DeadLetter{Conn: c}
+	n, err := d.Replay("foo", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("expected 1, got", n)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,335 @@
+package beanstalk
+
+import (
+	"bufio"
+	"container/list"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A DedupStore remembers the job id recorded for each key.
+type DedupStore interface {
+	// Get returns the id recorded for key, if any.
+	Get(key string) (id uint64, ok bool, err error)
+
+	// Set records id for key, replacing any earlier id.
+	Set(key string, id uint64) error
+
+	// Delete forgets key.
+	Delete(key string) error
+}
+
+// PutUnique puts a job into tube t as in Put, unless store records a
+// job for key that is still in t, in which case it returns that job's
+// id instead. A job is still in t if the server has it in any state;
+// once it is deleted, key can be used again. A retried PutUnique thus
+// puts at most one live job per key.
+//
+// The check is not atomic: concurrent calls with the same key, from
+// producers not sharing a store, can each put a job. Job ids start
+// again from 1 if the server restarts without a binlog, so a store
+// should not outlive the jobs on such a server.
+func (t *Tube) PutUnique(key string, store DedupStore, body []byte, pri uint32, delay, ttr time.Duration) (id uint64, err error) {
+	id, ok, err := store.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		stats, err := t.Conn.StatsJob(id)
+		if err == nil && stats["tube"] == t.Name {
+			return id, nil
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return 0, err
+		}
+	}
+	id, err = t.Put(body, pri, delay, ttr)
+	if err != nil {
+		return 0, err
+	}
+	return id, store.Set(key, id)
+}
+
+// MemoryDedup is a DedupStore that keeps up to a fixed number of keys
+// in memory, forgetting the least recently used first. If the number
+// is zero, there is no limit. Keys are also forgotten once they are
+// older than the TTL, if it is nonzero.
+type MemoryDedup struct {
+	size int
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	order *list.List // of *dedupEntry, most recently used first
+	keys  map[string]*list.Element
+}
+
+type dedupEntry struct {
+	key     string
+	id      uint64
+	expires time.Time
+}
+
+// NewMemoryDedup returns a MemoryDedup holding up to size keys, each
+// for up to ttl.
+func NewMemoryDedup(size int, ttl time.Duration) *MemoryDedup {
+	return &MemoryDedup{
+		size:  size,
+		ttl:   ttl,
+		order: list.New(),
+		keys:  make(map[string]*list.Element),
+	}
+}
+
+// Get returns the id recorded for key, unless key has expired or
+// been forgotten, and marks key as recently used.
+func (m *MemoryDedup) Get(key string) (uint64, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.keys[key]
+	if !ok {
+		return 0, false, nil
+	}
+	d := e.Value.(*dedupEntry)
+	if m.ttl > 0 && now().After(d.expires) {
+		m.order.Remove(e)
+		delete(m.keys, key)
+		return 0, false, nil
+	}
+	m.order.MoveToFront(e)
+	return d.id, true, nil
+}
+
+// Set records id for key, forgetting the least recently used key
+// if the MemoryDedup is full.
+func (m *MemoryDedup) Set(key string, id uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d := &dedupEntry{key, id, now().Add(m.ttl)}
+	if e, ok := m.keys[key]; ok {
+		e.Value = d
+		m.order.MoveToFront(e)
+		return nil
+	}
+	m.keys[key] = m.order.PushFront(d)
+	for m.size > 0 && m.order.Len() > m.size {
+		e := m.order.Back()
+		m.order.Remove(e)
+		delete(m.keys, e.Value.(*dedupEntry).key)
+	}
+	return nil
+}
+
+// Delete forgets key.
+func (m *MemoryDedup) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.keys[key]; ok {
+		m.order.Remove(e)
+		delete(m.keys, key)
+	}
+	return nil
+}
+
+// FileDedup is a DedupStore kept in a file, so it survives restarts.
+// Changes are appended to the file, which is rewritten without the
+// forgotten and expired keys when it has grown to twice their number.
+// Keys are forgotten once they are older than the TTL, if it is
+// nonzero.
+type FileDedup struct {
+	name string
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	f     *os.File
+	keys  map[string]dedupEntry
+	lines int
+}
+
+// OpenFileDedup opens the FileDedup in the named file, creating it if
+// necessary.
+func OpenFileDedup(name string, ttl time.Duration) (*FileDedup, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	d := &FileDedup{name: name, ttl: ttl, f: f, keys: make(map[string]dedupEntry)}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		e, err := parseDedupLine(sc.Text())
+		if err != nil {
+			// A line cut short by a crash can only be the last.
+			continue
+		}
+		d.lines++
+		if e.id == 0 {
+			delete(d.keys, e.key)
+		} else {
+			d.keys[e.key] = e
+		}
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := endLine(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// endLine ends a partly written last line in f, so it does not run
+// into the next one appended.
+func endLine(f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil || fi.Size() == 0 {
+		return err
+	}
+	b := make([]byte, 1)
+	if _, err := f.ReadAt(b, fi.Size()-1); err != nil {
+		return err
+	}
+	if b[0] != '\n' {
+		_, err = f.WriteString("\n")
+	}
+	return err
+}
+
+// A line is a quoted key, an id (0 to delete the key), and an expiry
+// time in Unix nanoseconds (0 for none).
+func (e dedupEntry) String() string {
+	var exp int64
+	if !e.expires.IsZero() {
+		exp = e.expires.UnixNano()
+	}
+	return fmt.Sprintf("%s %d %d\n", strconv.Quote(e.key), e.id, exp)
+}
+
+func parseDedupLine(s string) (e dedupEntry, err error) {
+	q, err := strconv.QuotedPrefix(s)
+	if err != nil {
+		return e, err
+	}
+	if e.key, err = strconv.Unquote(q); err != nil {
+		return e, err
+	}
+	f := strings.Fields(s[len(q):])
+	if len(f) != 2 {
+		return e, fmt.Errorf("bad dedup line %q", s)
+	}
+	if e.id, err = strconv.ParseUint(f[0], 10, 64); err != nil {
+		return e, err
+	}
+	exp, err := strconv.ParseInt(f[1], 10, 64)
+	if err != nil {
+		return e, err
+	}
+	if exp != 0 {
+		e.expires = time.Unix(0, exp)
+	}
+	return e, nil
+}
+
+// Get returns the id recorded for key, unless key has expired.
+func (d *FileDedup) Get(key string) (uint64, bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.keys[key]
+	if !ok || d.expired(e) {
+		return 0, false, nil
+	}
+	return e.id, true, nil
+}
+
+func (d *FileDedup) expired(e dedupEntry) bool {
+	return !e.expires.IsZero() && now().After(e.expires)
+}
+
+// Set records id, which must not be zero, for key and appends the
+// change to the file.
+func (d *FileDedup) Set(key string, id uint64) error {
+	if id == 0 {
+		return errors.New("dedup: job id 0")
+	}
+	e := dedupEntry{key: key, id: id}
+	if d.ttl > 0 {
+		e.expires = now().Add(d.ttl)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.keys[key] = e
+	return d.append(e)
+}
+
+// Delete forgets key and appends the change to the file.
+func (d *FileDedup) Delete(key string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.keys[key]; !ok {
+		return nil
+	}
+	delete(d.keys, key)
+	return d.append(dedupEntry{key: key})
+}
+
+func (d *FileDedup) append(e dedupEntry) error {
+	if _, err := d.f.WriteString(e.String()); err != nil {
+		return err
+	}
+	d.lines++
+	if d.lines > 2*len(d.keys)+64 {
+		return d.compact()
+	}
+	return nil
+}
+
+// compact rewrites the file with only the live keys, replacing it
+// atomically.
+func (d *FileDedup) compact() error {
+	f, err := os.CreateTemp(filepath.Dir(d.name), ".tmp-")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	n := 0
+	for k, e := range d.keys {
+		if d.expired(e) {
+			delete(d.keys, k)
+			continue
+		}
+		w.WriteString(e.String())
+		n++
+	}
+	err = w.Flush()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), d.name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	nf, err := os.OpenFile(d.name, os.O_RDWR|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	d.f.Close()
+	d.f, d.lines = nf, n
+	return nil
+}
+
+// Close closes the file.
+func (d *FileDedup) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.f.Close()
+}
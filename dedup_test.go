@@ -0,0 +1,129 @@
+package beanstalk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPutUnique(t *testing.T) {
+	c := NewConn(mock(
+		"put 0 0 10 1\r\na\r\n"+
+			"stats-job 1\r\n"+
+			"stats-job 1\r\nput 0 0 10 1\r\na\r\n"+
+			"stats-job 2\r\nput 0 0 10 1\r\na\r\n",
+		"INSERTED 1\r\n"+
+			"OK 18\r\n---\ntube: default\n\r\n"+
+			"NOT_FOUND\r\nINSERTED 2\r\n"+
+			"OK 16\r\n---\ntube: other\n\r\nINSERTED 3\r\n",
+	))
+	store := NewMemoryDedup(10, 0)
+	for i, want := range []uint64{1, 1, 2, 3} {
+		id, err := c.PutUnique("k", store, []byte("a"), 0, 0, 10*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != want {
+			t.Fatalf("put %d: expected %d, got %d", i, want, id)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testDedupStore checks the behavior common to DedupStores with a TTL
+// of one minute.
+func testDedupStore(t *testing.T, d DedupStore) {
+	t0 := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	setNow(t, t0)
+	if _, ok, err := d.Get("a"); ok || err != nil {
+		t.Fatalf("got %v, %v for unset key", ok, err)
+	}
+	for _, k := range []string{"a", "b c\n", "a"} {
+		if err := d.Set(k, uint64(len(k))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if id, ok, err := d.Get("b c\n"); id != 4 || !ok || err != nil {
+		t.Fatalf("got %d, %v, %v", id, ok, err)
+	}
+	if err := d.Delete("b c\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := d.Get("b c\n"); ok {
+		t.Fatal("deleted key still set")
+	}
+	setNow(t, t0.Add(2*time.Minute))
+	if _, ok, _ := d.Get("a"); ok {
+		t.Fatal("expired key still set")
+	}
+}
+
+func TestMemoryDedup(t *testing.T) {
+	testDedupStore(t, NewMemoryDedup(10, time.Minute))
+
+	m := NewMemoryDedup(2, 0)
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Get("a")
+	m.Set("c", 3) // evicts b, the least recently used
+	for k, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok, _ := m.Get(k); ok != want {
+			t.Errorf("key %q: got %v, want %v", k, ok, want)
+		}
+	}
+}
+
+func TestFileDedup(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "dedup")
+	d, err := OpenFileDedup(name, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDedupStore(t, d)
+	setNow(t, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err := d.Set("kept", 9); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash part way through writing a line.
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`"torn`)
+	f.Close()
+
+	d, err = OpenFileDedup(name, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set("new", 10); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ { // enough to compact
+		if err := d.Set("churn", 11); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d.Close()
+
+	d, err = OpenFileDedup(name, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	for k, want := range map[string]uint64{"kept": 9, "new": 10, "churn": 11, "a": 1} {
+		if id, _, _ := d.Get(k); id != want {
+			t.Errorf("key %q: got %d, want %d", k, id, want)
+		}
+	}
+	if d.lines >= 100 {
+		t.Errorf("file has %d lines; expected it to be compacted", d.lines)
+	}
+}
@@ -0,0 +1,133 @@
+package beanstalk
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Header names with defined meanings in an Envelope.
+const (
+	HeaderContentType   = "Content-Type"
+	HeaderCorrelationID = "Correlation-Id"
+	HeaderProducer      = "Producer"
+	HeaderEnqueuedAt    = "Enqueued-At" // RFC 3339, set by PutEnvelope
+	HeaderSchemaVersion = "Schema-Version"
+)
+
+const envelopeVersion = 1
+
+var (
+	envelopeMagic = []byte("\x00BSE")
+	crnlcrnl      = []byte("\r\n\r\n")
+)
+
+// ErrBadEnvelope indicates that a body starts like an envelope
+// but cannot be parsed as one.
+var ErrBadEnvelope = errors.New("malformed envelope")
+
+// An Envelope is a job body carrying a set of headers.
+//
+// An encoded envelope starts with a magic prefix and a version byte,
+// followed by MIME-style header lines, a blank line, and the payload.
+// Bodies without the prefix are treated as legacy bodies with no
+// headers.
+type Envelope struct {
+	Header textproto.MIMEHeader
+	Body   []byte
+}
+
+// ParseEnvelope decodes b. If b is not an encoded envelope, it is
+// returned as the Body of an envelope with an empty header.
+func ParseEnvelope(b []byte) (*Envelope, error) {
+	if !bytes.HasPrefix(b, envelopeMagic) {
+		return &Envelope{textproto.MIMEHeader{}, b}, nil
+	}
+	b = b[len(envelopeMagic):]
+	if len(b) == 0 {
+		return nil, ErrBadEnvelope
+	}
+	if b[0] != envelopeVersion {
+		return nil, fmt.Errorf("unsupported envelope version %d", b[0])
+	}
+	b = b[1:]
+	if bytes.HasPrefix(b, crnl) {
+		return &Envelope{textproto.MIMEHeader{}, b[len(crnl):]}, nil
+	}
+	i := bytes.Index(b, crnlcrnl)
+	if i == -1 {
+		return nil, ErrBadEnvelope
+	}
+	i += len(crnlcrnl)
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(b[:i])))
+	h, err := r.ReadMIMEHeader()
+	if err != nil {
+		return nil, ErrBadEnvelope
+	}
+	return &Envelope{h, b[i:]}, nil
+}
+
+// MarshalBinary encodes e. Header keys are written in sorted order.
+func (e *Envelope) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	b.Write(envelopeMagic)
+	b.WriteByte(envelopeVersion)
+	keys := make([]string, 0, len(e.Header))
+	for k := range e.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if k == "" || strings.ContainsAny(k, ":\r\n") {
+			return nil, fmt.Errorf("bad envelope header key %q", k)
+		}
+		for _, v := range e.Header[k] {
+			if strings.ContainsAny(v, "\r\n") {
+				return nil, fmt.Errorf("bad envelope header value %q", v)
+			}
+			b.WriteString(k)
+			b.Write(colonSpace)
+			b.WriteString(v)
+			b.Write(crnl)
+		}
+	}
+	b.Write(crnl)
+	b.Write(e.Body)
+	return b.Bytes(), nil
+}
+
+// PutEnvelope encodes e and puts it into tube t. If e has no
+// Enqueued-At header, the current time is recorded in the
+// encoded copy. See Put for the meaning of the other arguments.
+func (t *Tube) PutEnvelope(e *Envelope, pri uint32, delay, ttr time.Duration) (id uint64, err error) {
+	if e.Header.Get(HeaderEnqueuedAt) == "" {
+		h := make(textproto.MIMEHeader, len(e.Header)+1)
+		for k, v := range e.Header {
+			h[k] = v
+		}
+		h.Set(HeaderEnqueuedAt, now().UTC().Format(time.RFC3339Nano))
+		e = &Envelope{h, e.Body}
+	}
+	body, err := e.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	return t.Put(body, pri, delay, ttr)
+}
+
+// ReserveEnvelope reserves a job as in Reserve and decodes its body
+// with ParseEnvelope. If the body cannot be decoded, ReserveEnvelope
+// returns the id of the job along with the error.
+func (t *TubeSet) ReserveEnvelope(timeout time.Duration) (id uint64, e *Envelope, err error) {
+	id, body, err := t.Reserve(timeout)
+	if err != nil {
+		return 0, nil, err
+	}
+	e, err = ParseEnvelope(body)
+	return id, e, err
+}
@@ -0,0 +1,129 @@
+package beanstalk
+
+import (
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	in := &Envelope{textproto.MIMEHeader{}, []byte("hello")}
+	in.Header.Set(HeaderContentType, "text/plain")
+	in.Header.Set(HeaderCorrelationID, "abc")
+	b, err := in.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "\x00BSE\x01Content-Type: text/plain\r\nCorrelation-Id: abc\r\n\r\nhello"
+	if string(b) != exp {
+		t.Fatalf("expected %#v, got %#v", exp, string(b))
+	}
+	out, err := ParseEnvelope(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("expected %#v, got %#v", in, out)
+	}
+}
+
+func TestEnvelopeEmptyHeader(t *testing.T) {
+	b, err := (&Envelope{Body: []byte("x")}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := ParseEnvelope(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Header) != 0 || string(e.Body) != "x" {
+		t.Fatalf("got %#v", e)
+	}
+}
+
+func TestEnvelopeLegacy(t *testing.T) {
+	e, err := ParseEnvelope([]byte("plain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Header) != 0 || string(e.Body) != "plain" {
+		t.Fatalf("got %#v", e)
+	}
+}
+
+func TestEnvelopeBad(t *testing.T) {
+	for _, s := range []string{"\x00BSE", "\x00BSE\x02\r\n", "\x00BSE\x01A: b\r\n"} {
+		if _, err := ParseEnvelope([]byte(s)); err == nil {
+			t.Fatalf("%#v: expected error", s)
+		}
+	}
+}
+
+func TestEnvelopeBadHeader(t *testing.T) {
+	e := &Envelope{textproto.MIMEHeader{"A": {"b\r\nc"}}, nil}
+	if _, err := e.MarshalBinary(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestPutEnvelope(t *testing.T) {
+	c := NewConn(mock(
+		"put 0 0 0 60\r\n\x00BSE\x01Enqueued-At: 2000-01-01T00:00:00Z\r\nProducer: test\r\n\r\nhi\r\n",
+		"INSERTED 1\r\n",
+	))
+	e := &Envelope{textproto.MIMEHeader{}, []byte("hi")}
+	e.Header.Set(HeaderProducer, "test")
+	e.Header.Set(HeaderEnqueuedAt, "2000-01-01T00:00:00Z")
+	id, err := c.PutEnvelope(e, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 {
+		t.Fatal("expected 1, got", id)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPutEnvelopeSetsEnqueuedAt(t *testing.T) {
+	var body string
+	c := NewConn(recordIO(&body, "INSERTED 1\r\n"))
+	e := &Envelope{textproto.MIMEHeader{}, []byte("hi")}
+	if _, err := c.PutEnvelope(e, 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if e.Header.Get(HeaderEnqueuedAt) != "" {
+		t.Fatal("PutEnvelope modified its argument")
+	}
+	i := strings.Index(body, "\r\n")
+	got, err := ParseEnvelope([]byte(body[i+2 : len(body)-2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = time.Parse(time.RFC3339Nano, got.Header.Get(HeaderEnqueuedAt)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReserveEnvelope(t *testing.T) {
+	c := NewConn(mock(
+		"reserve-with-timeout 1\r\n",
+		"RESERVED 1 15\r\n\x00BSE\x01A: b\r\n\r\nhi\r\n",
+	))
+	id, e, err := c.ReserveEnvelope(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 {
+		t.Fatal("expected 1, got", id)
+	}
+	if e.Header.Get("A") != "b" || string(e.Body) != "hi" {
+		t.Fatalf("got %#v", e)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,6 +1,7 @@
 package beanstalk_test
 
 import (
+	"context"
 	"fmt"
 	"github.com/beanstalkd/go-beanstalk"
 	"time"
@@ -43,3 +44,13 @@ func Example_putOtherTube() {
 	}
 	fmt.Println("job", id)
 }
+
+func Example_consume() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for job := range conn.Consume(ctx, beanstalk.ConsumeOptions{Prefetch: 1}) {
+		fmt.Println("job", job.ID)
+		fmt.Println(string(job.Body))
+		conn.Delete(job.ID)
+	}
+}
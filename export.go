@@ -0,0 +1,182 @@
+package beanstalk
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+// A Record is a job as written by Export and read by Import.
+type Record struct {
+	Tube  string `json:"tube"`
+	State string `json:"state"` // "ready", "delayed", or "buried"
+	ID    uint64 `json:"id"`    // id on the server it was exported from
+	Pri   uint32 `json:"pri"`
+	Delay int64  `json:"delay"` // seconds remaining, for delayed jobs
+	TTR   int64  `json:"ttr"`   // seconds
+	Body  []byte `json:"body"`
+}
+
+// Put puts a job described by r into its tube on c, in its recorded
+// state, and returns the id of the new job.
+func (r *Record) Put(c *Conn) (id uint64, err error) {
+	t := NewTube(c, r.Tube)
+	delay := time.Duration(r.Delay) * time.Second
+	if r.State == "buried" {
+		// keep it out of reach of consumers until it is buried
+		delay = MaxDelay
+	}
+	id, err = t.Put(r.Body, r.Pri, delay, time.Duration(r.TTR)*time.Second)
+	if err != nil || r.State != "buried" {
+		return id, err
+	}
+	if _, err = c.ReserveJob(id); err != nil {
+		return id, err
+	}
+	return id, c.Bury(id, r.Pri)
+}
+
+// takenJob is a job reserved by take, with what is needed to put
+// it back as it was.
+type takenJob struct {
+	Record
+	readyAt time.Time
+}
+
+// take reserves the next job in the given state from t, which must
+// be "ready", "delayed", or "buried". It returns ErrNotFound when
+// there are no more. If another client reserves or deletes the job
+// it peeked first, take peeks again.
+func (t *Tube) take(state string) (*takenJob, error) {
+	var id uint64
+	var info jobInfo
+	var body []byte
+	for {
+		var err error
+		switch state {
+		case "ready":
+			id, _, err = t.PeekReady()
+		case "delayed":
+			id, _, err = t.PeekDelayed()
+		case "buried":
+			id, _, err = t.PeekBuried()
+		default:
+			return nil, errors.New("unknown job state " + state)
+		}
+		if err != nil {
+			return nil, err
+		}
+		info, err = t.Conn.statsJob(id)
+		if err == nil {
+			body, err = t.Conn.ReserveJob(id)
+		}
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+	j := &takenJob{Record: Record{
+		Tube:  t.Name,
+		State: info.state,
+		ID:    id,
+		Pri:   info.pri,
+		TTR:   int64(info.ttr / time.Second),
+		Body:  body,
+	}}
+	if info.state == "delayed" {
+		j.Delay = int64(info.timeLeft / time.Second)
+		j.readyAt = now().Add(info.timeLeft)
+	}
+	return j, nil
+}
+
+// restore returns a job reserved by take to its previous state.
+func (j *takenJob) restore(c *Conn) error {
+	switch j.State {
+	case "delayed":
+		return c.ReleaseAt(j.ID, j.Pri, j.readyAt)
+	case "buried":
+		return c.Bury(j.ID, j.Pri)
+	}
+	return c.Release(j.ID, j.Pri, 0)
+}
+
+// Export writes the ready, delayed, and buried jobs in the named
+// tubes on c to w in JSON Lines format, one Record per line, and
+// returns the number of jobs written. Reserved jobs are not exported.
+//
+// If drain is true, each job is deleted once written. Otherwise jobs
+// are held reserved until all are written, then put back in their
+// previous state. Jobs whose TTR expires in the meantime return to
+// the ready queue early; each is still written only once.
+func Export(w io.Writer, c *Conn, tubes []string, drain bool) (n int, err error) {
+	var held []*takenJob
+	seen := make(map[uint64]bool)
+	defer func() {
+		for _, j := range held {
+			if rerr := j.restore(c); err == nil {
+				err = rerr
+			}
+		}
+	}()
+	enc := json.NewEncoder(w)
+	for _, name := range tubes {
+		t := NewTube(c, name)
+		for _, state := range []string{"ready", "delayed", "buried"} {
+			for {
+				j, err := t.take(state)
+				if errors.Is(err, ErrNotFound) {
+					break
+				}
+				if err != nil {
+					return n, err
+				}
+				if drain {
+					err = enc.Encode(&j.Record)
+					if err == nil {
+						err = c.Delete(j.ID)
+					} else {
+						j.restore(c)
+					}
+				} else {
+					if seen[j.ID] {
+						// its TTR ran out and we took it again; it is
+						// already held and written
+						continue
+					}
+					seen[j.ID] = true
+					held = append(held, j)
+					err = enc.Encode(&j.Record)
+				}
+				if err != nil {
+					return n, err
+				}
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+// Import reads Records in JSON Lines format from r, as written by
+// Export, and puts each one on c. It returns the number of jobs put.
+func Import(r io.Reader, c *Conn) (n int, err error) {
+	dec := json.NewDecoder(r)
+	for {
+		var rec Record
+		err = dec.Decode(&rec)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if _, err = rec.Put(c); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
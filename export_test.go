@@ -0,0 +1,124 @@
+package beanstalk
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const exportStats = "---\ntube: foo\nstate: delayed\npri: 3\nttr: 10\ntime-left: 5\n"
+
+func TestExport(t *testing.T) {
+	t0 := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	setNow(t, t0)
+	c := NewConn(mock(
+		"use foo\r\npeek-ready\r\npeek-delayed\r\nstats-job 1\r\nreserve-job 1\r\n"+
+			"peek-delayed\r\npeek-buried\r\nrelease 1 3 5\r\n",
+		"USING foo\r\nNOT_FOUND\r\nFOUND 1 1\r\nx\r\nOK 57\r\n"+exportStats+"\r\n"+
+			"RESERVED 1 1\r\nx\r\nNOT_FOUND\r\nNOT_FOUND\r\nRELEASED\r\n",
+	))
+	var b bytes.Buffer
+	n, err := Export(&b, c, []string{"foo"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("expected 1, got", n)
+	}
+	exp := `{"tube":"foo","state":"delayed","id":1,"pri":3,"delay":5,"ttr":10,"body":"eA=="}` + "\n"
+	if b.String() != exp {
+		t.Fatalf("expected %#v, got %#v", exp, b.String())
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportExpired(t *testing.T) {
+	// job 1 outlives its TTR and is peeked again
+	stats := "OK 41\r\n---\ntube: foo\nstate: ready\npri: 3\nttr: 1\n\r\n"
+	c := NewConn(mock(
+		"use foo\r\npeek-ready\r\nstats-job 1\r\nreserve-job 1\r\n"+
+			"peek-ready\r\nstats-job 1\r\nreserve-job 1\r\n"+
+			"peek-ready\r\npeek-delayed\r\npeek-buried\r\nrelease 1 3 0\r\n",
+		"USING foo\r\nFOUND 1 1\r\nx\r\n"+stats+"RESERVED 1 1\r\nx\r\n"+
+			"FOUND 1 1\r\nx\r\n"+stats+"RESERVED 1 1\r\nx\r\n"+
+			"NOT_FOUND\r\nNOT_FOUND\r\nNOT_FOUND\r\nRELEASED\r\n",
+	))
+	var b bytes.Buffer
+	n, err := Export(&b, c, []string{"foo"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || strings.Count(b.String(), "\n") != 1 {
+		t.Fatalf("expected 1 record, got %d: %#v", n, b.String())
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportLostRace(t *testing.T) {
+	setNow(t, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	// a consumer deletes job 1 between our peek and stats-job
+	c := NewConn(mock(
+		"use foo\r\npeek-ready\r\npeek-delayed\r\nstats-job 1\r\n"+
+			"peek-delayed\r\nstats-job 2\r\nreserve-job 2\r\n"+
+			"peek-delayed\r\npeek-buried\r\nrelease 2 3 5\r\n",
+		"USING foo\r\nNOT_FOUND\r\nFOUND 1 1\r\nx\r\nNOT_FOUND\r\n"+
+			"FOUND 2 1\r\ny\r\nOK 57\r\n"+exportStats+"\r\nRESERVED 2 1\r\ny\r\n"+
+			"NOT_FOUND\r\nNOT_FOUND\r\nRELEASED\r\n",
+	))
+	var b bytes.Buffer
+	n, err := Export(&b, c, []string{"foo"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("expected 1, got", n)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportDrain(t *testing.T) {
+	c := NewConn(mock(
+		"use foo\r\npeek-ready\r\npeek-delayed\r\nstats-job 1\r\nreserve-job 1\r\ndelete 1\r\n"+
+			"peek-delayed\r\npeek-buried\r\n",
+		"USING foo\r\nNOT_FOUND\r\nFOUND 1 1\r\nx\r\nOK 57\r\n"+exportStats+"\r\n"+
+			"RESERVED 1 1\r\nx\r\nDELETED\r\nNOT_FOUND\r\nNOT_FOUND\r\n",
+	))
+	var b bytes.Buffer
+	n, err := Export(&b, c, []string{"foo"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("expected 1, got", n)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	in := `{"tube":"foo","state":"delayed","id":1,"pri":3,"delay":5,"ttr":10,"body":"eA=="}
+{"tube":"foo","state":"buried","id":2,"pri":4,"delay":0,"ttr":10,"body":"eQ=="}
+`
+	c := NewConn(mock(
+		"use foo\r\nput 3 5 10 1\r\nx\r\nput 4 4294967295 10 1\r\ny\r\nreserve-job 8\r\nbury 8 4\r\n",
+		"USING foo\r\nINSERTED 7\r\nINSERTED 8\r\nRESERVED 8 1\r\ny\r\nBURIED\r\n",
+	))
+	n, err := Import(strings.NewReader(in), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatal("expected 2, got", n)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
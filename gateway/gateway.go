@@ -0,0 +1,473 @@
+// Package gateway exposes beanstalkd over HTTP, for clients that can't
+// speak the TCP protocol.
+//
+// The endpoints are:
+//
+//	POST   /tubes/{tube}/jobs      put the request body as a job
+//	POST   /tubes/{tube}/reserve   reserve a job
+//	DELETE /jobs/{id}              delete a reserved job
+//	POST   /jobs/{id}/release      release a reserved job
+//	POST   /jobs/{id}/bury         bury a reserved job
+//	POST   /jobs/{id}/touch        touch a reserved job
+//	GET    /stats                  server statistics, as JSON
+//
+// Parameters are passed in the query string. Durations are either a
+// number of seconds or a Go duration such as "90s". Put takes pri,
+// delay, and ttr and replies 201 with the job id as JSON. Reserve takes
+// timeout; it replies 200 with the job body, and the job id and TTR in
+// the Job-Id and Job-Ttr headers, or 204 if no job became ready in
+// time. Release takes pri and delay, and bury takes pri; if pri is
+// omitted the job keeps its current priority.
+//
+// The server only lets the connection that reserved a job delete,
+// release, bury, or touch it, so the gateway remembers which of its
+// connections reserved each job and sends those commands there.
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+// Defaults for Gateway fields left zero.
+const (
+	DefaultPoolSize   = 4
+	DefaultMaxTimeout = 30 * time.Second
+	DefaultPri        = 1024
+	DefaultTTR        = time.Minute
+)
+
+// Gateway is an http.Handler that serves the endpoints listed in the
+// package documentation using a pool of connections.
+//
+// A reserve holds a connection from the pool for up to its timeout.
+// Commands for a job go to the connection that reserved it even if
+// that connection is in use, so they can wait behind another reserve;
+// MaxTimeout bounds that wait.
+type Gateway struct {
+	// Dial opens a new connection. It is called lazily, and again to
+	// replace a connection that fails.
+	Dial func() (*beanstalk.Conn, error)
+
+	// Size is the number of connections in the pool. If zero,
+	// DefaultPoolSize is used.
+	Size int
+
+	// MaxTimeout is the longest reserve timeout allowed. If zero,
+	// DefaultMaxTimeout is used.
+	MaxTimeout time.Duration
+
+	// MaxBody is the largest job body accepted by put, in bytes. If
+	// zero, there is no limit other than the server's.
+	MaxBody int64
+
+	once sync.Once
+	pool chan *beanstalk.Conn // nil entries are not yet dialed
+
+	mu   sync.Mutex
+	jobs map[uint64]*reservation
+	dead map[*beanstalk.Conn]bool
+}
+
+type reservation struct {
+	c       *beanstalk.Conn
+	ttr     time.Duration
+	expires time.Time
+}
+
+// New returns a Gateway that opens connections with dial.
+func New(dial func() (*beanstalk.Conn, error)) *Gateway {
+	return &Gateway{Dial: dial}
+}
+
+func (g *Gateway) init() {
+	g.once.Do(func() {
+		size := g.Size
+		if size <= 0 {
+			size = DefaultPoolSize
+		}
+		g.pool = make(chan *beanstalk.Conn, size)
+		for i := 0; i < size; i++ {
+			g.pool <- nil
+		}
+		g.jobs = make(map[uint64]*reservation)
+		g.dead = make(map[*beanstalk.Conn]bool)
+	})
+}
+
+// Close closes the connections in the pool that are not in use.
+func (g *Gateway) Close() error {
+	g.init()
+	var err error
+	for {
+		select {
+		case c := <-g.pool:
+			if c != nil {
+				if cerr := c.Close(); err == nil {
+					err = cerr
+				}
+			}
+		default:
+			return err
+		}
+	}
+}
+
+// get takes a connection from the pool, dialing if needed.
+func (g *Gateway) get(r *http.Request) (*beanstalk.Conn, error) {
+	g.init()
+	var c *beanstalk.Conn
+	select {
+	case c = <-g.pool:
+	case <-r.Context().Done():
+		return nil, r.Context().Err()
+	}
+	g.mu.Lock()
+	if g.dead[c] {
+		delete(g.dead, c)
+		c = nil
+	}
+	g.mu.Unlock()
+	if c == nil {
+		var err error
+		if c, err = g.Dial(); err != nil {
+			g.pool <- nil
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// put returns c to the pool after a command that returned err.
+func (g *Gateway) put(c *beanstalk.Conn, err error) {
+	if broken(err) {
+		g.fail(c)
+		g.mu.Lock()
+		delete(g.dead, c)
+		g.mu.Unlock()
+		c = nil
+	}
+	g.pool <- c
+}
+
+// fail closes c and forgets the jobs reserved on it.
+func (g *Gateway) fail(c *beanstalk.Conn) {
+	c.Close()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.dead[c] = true
+	for id, res := range g.jobs {
+		if res.c == c {
+			delete(g.jobs, id)
+		}
+	}
+}
+
+// broken reports whether err means the connection is unusable, rather
+// than being a response from the server. Tube names are checked before
+// a connection is used, so any other error, even a NameError, is taken
+// to mean the connection can't be trusted.
+func broken(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, e := range []error{
+		beanstalk.ErrBadFormat, beanstalk.ErrBuried, beanstalk.ErrDeadline,
+		beanstalk.ErrDraining, beanstalk.ErrInternal, beanstalk.ErrJobTooBig,
+		beanstalk.ErrNotFound, beanstalk.ErrNotIgnored, beanstalk.ErrOOM,
+		beanstalk.ErrTimeout,
+	} {
+		if errors.Is(err, e) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	method := http.MethodPost
+	var serve func()
+	switch {
+	case len(parts) == 1 && parts[0] == "stats":
+		method = http.MethodGet
+		serve = func() { g.serveStats(w, r) }
+	case len(parts) == 3 && parts[0] == "tubes" && parts[2] == "jobs":
+		serve = func() { g.servePut(w, r, parts[1]) }
+	case len(parts) == 3 && parts[0] == "tubes" && parts[2] == "reserve":
+		serve = func() { g.serveReserve(w, r, parts[1]) }
+	case len(parts) == 2 && parts[0] == "jobs":
+		method = http.MethodDelete
+		serve = func() { g.serveJob(w, r, parts[1], "delete") }
+	case len(parts) == 3 && parts[0] == "jobs" &&
+		(parts[2] == "release" || parts[2] == "bury" || parts[2] == "touch"):
+		serve = func() { g.serveJob(w, r, parts[1], parts[2]) }
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	serve()
+}
+
+func (g *Gateway) serveStats(w http.ResponseWriter, r *http.Request) {
+	c, err := g.get(r)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	stats, err := c.Stats()
+	g.put(c, err)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	serveJSON(w, http.StatusOK, stats)
+}
+
+func (g *Gateway) servePut(w http.ResponseWriter, r *http.Request, tube string) {
+	if err := beanstalk.CheckName(tube); err != nil {
+		serveError(w, err)
+		return
+	}
+	q := r.URL.Query()
+	pri, err := parsePri(q.Get("pri"), DefaultPri)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	delay, err := parseDuration(q.Get("delay"), 0)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	ttr, err := parseDuration(q.Get("ttr"), DefaultTTR)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	var body io.Reader = r.Body
+	if g.MaxBody > 0 {
+		body = http.MaxBytesReader(w, r.Body, g.MaxBody)
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	c, err := g.get(r)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	id, err := beanstalk.NewTube(c, tube).Put(b, pri, delay, ttr)
+	g.put(c, err)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	serveJSON(w, http.StatusCreated, map[string]uint64{"id": id})
+}
+
+func (g *Gateway) serveReserve(w http.ResponseWriter, r *http.Request, tube string) {
+	if err := beanstalk.CheckName(tube); err != nil {
+		serveError(w, err)
+		return
+	}
+	max := g.MaxTimeout
+	if max <= 0 {
+		max = DefaultMaxTimeout
+	}
+	timeout, err := parseDuration(r.URL.Query().Get("timeout"), 0)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	if timeout > max {
+		timeout = max
+	}
+	c, err := g.get(r)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	id, body, err := beanstalk.NewTubeSet(c, tube).Reserve(timeout)
+	var res *reservation
+	if err == nil {
+		res, err = g.reserved(c, id)
+	}
+	g.put(c, err)
+	if errors.Is(err, beanstalk.ErrTimeout) || errors.Is(err, beanstalk.ErrDeadline) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Job-Id", strconv.FormatUint(id, 10))
+	w.Header().Set("Job-Ttr", strconv.Itoa(int(res.ttr/time.Second)))
+	w.Write(body)
+}
+
+// reserved records that job id was reserved on c.
+func (g *Gateway) reserved(c *beanstalk.Conn, id uint64) (*reservation, error) {
+	stats, err := c.StatsJob(id)
+	if err != nil {
+		return nil, err
+	}
+	ttr, _ := strconv.Atoi(stats["ttr"])
+	left, _ := strconv.Atoi(stats["time-left"])
+	now := time.Now()
+	res := &reservation{c, time.Duration(ttr) * time.Second, now.Add(time.Duration(left+1) * time.Second)}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for id, old := range g.jobs {
+		if now.After(old.expires) {
+			delete(g.jobs, id)
+		}
+	}
+	g.jobs[id] = res
+	return res, nil
+}
+
+func (g *Gateway) serveJob(w http.ResponseWriter, r *http.Request, s, op string) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	g.init()
+	g.mu.Lock()
+	res := g.jobs[id]
+	g.mu.Unlock()
+	if res == nil {
+		http.Error(w, "job not reserved through this gateway", http.StatusNotFound)
+		return
+	}
+	c := res.c
+	q := r.URL.Query()
+	done := true
+	switch op {
+	case "delete":
+		err = c.Delete(id)
+	case "release":
+		var pri uint32
+		var delay time.Duration
+		if pri, err = g.pri(c, id, q.Get("pri")); err != nil {
+			break
+		}
+		if delay, err = parseDuration(q.Get("delay"), 0); err != nil {
+			break
+		}
+		err = c.Release(id, pri, delay)
+	case "bury":
+		var pri uint32
+		if pri, err = g.pri(c, id, q.Get("pri")); err == nil {
+			err = c.Bury(id, pri)
+		}
+	case "touch":
+		done = false
+		if err = c.Touch(id); err == nil {
+			g.mu.Lock()
+			res.expires = time.Now().Add(res.ttr + time.Second)
+			g.mu.Unlock()
+		}
+	}
+	if broken(err) {
+		g.fail(c)
+	} else if done && err == nil || errors.Is(err, beanstalk.ErrNotFound) {
+		g.mu.Lock()
+		delete(g.jobs, id)
+		g.mu.Unlock()
+	}
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pri parses s as a priority, defaulting to the current priority of
+// job id.
+func (g *Gateway) pri(c *beanstalk.Conn, id uint64, s string) (uint32, error) {
+	if s != "" {
+		return parsePri(s, 0)
+	}
+	stats, err := c.StatsJob(id)
+	if err != nil {
+		return 0, err
+	}
+	return parsePri(stats["pri"], 0)
+}
+
+type paramError struct {
+	name, value string
+}
+
+func (e paramError) Error() string {
+	return fmt.Sprintf("invalid %s %q", e.name, e.value)
+}
+
+func parsePri(s string, def uint32) (uint32, error) {
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, paramError{"priority", s}
+	}
+	return uint32(n), nil
+}
+
+// parseDuration parses s as a number of seconds or a Go duration.
+func parseDuration(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, paramError{"duration", s}
+	}
+	return d, nil
+}
+
+func serveJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func serveError(w http.ResponseWriter, err error) {
+	code := http.StatusBadGateway
+	var perr paramError
+	var nerr beanstalk.NameError
+	switch {
+	case errors.As(err, &perr), errors.As(err, &nerr):
+		code = http.StatusBadRequest
+	case errors.Is(err, beanstalk.ErrNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, beanstalk.ErrJobTooBig):
+		code = http.StatusRequestEntityTooLarge
+	case errors.Is(err, beanstalk.ErrDraining), errors.Is(err, beanstalk.ErrOOM):
+		code = http.StatusServiceUnavailable
+	}
+	http.Error(w, err.Error(), code)
+}
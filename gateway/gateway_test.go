@@ -0,0 +1,141 @@
+package gateway
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+// fakeConn replies with a canned response and records what it is sent.
+type fakeConn struct {
+	io.Reader
+	sent bytes.Buffer
+}
+
+func (f *fakeConn) Write(b []byte) (int, error) { return f.sent.Write(b) }
+func (f *fakeConn) Close() error                { return nil }
+
+// newGateway returns a Gateway with one pooled connection whose nth
+// dial returns the nth of conns, which replies with resps[n].
+func newGateway(resps ...string) (g *Gateway, conns []*fakeConn) {
+	for _, resp := range resps {
+		conns = append(conns, &fakeConn{Reader: strings.NewReader(resp)})
+	}
+	dialed := 0
+	g = New(func() (*beanstalk.Conn, error) {
+		dialed++
+		return beanstalk.NewConn(conns[dialed-1]), nil
+	})
+	g.Size = 1
+	return g, conns
+}
+
+func do(g *Gateway, method, url, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+	return w
+}
+
+func TestPut(t *testing.T) {
+	var sent *fakeConn
+	g := New(func() (*beanstalk.Conn, error) {
+		sent = &fakeConn{Reader: strings.NewReader("USING foo\r\nINSERTED 5\r\n")}
+		return beanstalk.NewConn(sent), nil
+	})
+	w := do(g, "POST", "/tubes/foo/jobs?pri=3&delay=2m&ttr=10", "hello")
+	if w.Code != http.StatusCreated || w.Body.String() != "{\"id\":5}\n" {
+		t.Fatalf("status %d body %q", w.Code, w.Body)
+	}
+	if got := sent.sent.String(); got != "use foo\r\nput 3 120 10 5\r\nhello\r\n" {
+		t.Fatalf("sent %#v", got)
+	}
+}
+
+func TestReserveAffinity(t *testing.T) {
+	g, conns := newGateway(
+		"WATCHING 2\r\nWATCHING 1\r\nRESERVED 7 2\r\nhi\r\nOK 33\r\n---\nttr: 60\ntime-left: 59\npri: 8\n\r\n" +
+			"OK 33\r\n---\nttr: 60\ntime-left: 59\npri: 8\n\r\nRELEASED\r\n",
+	)
+	w := do(g, "POST", "/tubes/foo/reserve?timeout=1", "")
+	if w.Code != http.StatusOK || w.Body.String() != "hi" {
+		t.Fatalf("status %d body %q", w.Code, w.Body)
+	}
+	if w.Header().Get("Job-Id") != "7" || w.Header().Get("Job-Ttr") != "60" {
+		t.Fatalf("header %v", w.Header())
+	}
+
+	// Hold the only pooled connection; the release must still go to it.
+	c := <-g.pool
+	if w = do(g, "POST", "/jobs/7/release?delay=5", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("status %d body %q", w.Code, w.Body)
+	}
+	g.pool <- c
+	if got := conns[0].sent.String(); !strings.HasSuffix(got, "stats-job 7\r\nrelease 7 8 5\r\n") {
+		t.Fatalf("sent %#v", got)
+	}
+	if w = do(g, "DELETE", "/jobs/7", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("status %d after release", w.Code)
+	}
+}
+
+func TestReserveTimeout(t *testing.T) {
+	// A broken connection is replaced on the next request.
+	g, conns := newGateway("", "WATCHING 2\r\nWATCHING 1\r\nTIMED_OUT\r\n")
+	g.MaxTimeout = 2 * time.Second
+	if w := do(g, "POST", "/tubes/foo/reserve?timeout=1m", ""); w.Code != http.StatusBadGateway {
+		t.Fatalf("status %d", w.Code)
+	}
+	if w := do(g, "POST", "/tubes/foo/reserve?timeout=1m", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("status %d body %q", w.Code, w.Body)
+	}
+	if got := conns[1].sent.String(); !strings.HasSuffix(got, "reserve-with-timeout 2\r\n") {
+		t.Fatalf("sent %#v", got)
+	}
+}
+
+func TestBadRequest(t *testing.T) {
+	g, _ := newGateway()
+	for _, tt := range []struct {
+		method, url string
+		code        int
+	}{
+		{"POST", "/tubes/foo/jobs?pri=-1", http.StatusBadRequest},
+		{"POST", "/tubes/foo/reserve?timeout=soon", http.StatusBadRequest},
+		{"POST", "/jobs/3/touch", http.StatusNotFound},
+		{"GET", "/jobs/3/touch", http.StatusMethodNotAllowed},
+		{"GET", "/nope", http.StatusNotFound},
+	} {
+		if w := do(g, tt.method, tt.url, ""); w.Code != tt.code {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.url, w.Code, tt.code)
+		}
+	}
+}
+
+func TestBadTubeThenStats(t *testing.T) {
+	g, conns := newGateway("OK 12\r\n---\npid: 42\n\r\n")
+	if w := do(g, "POST", "/tubes/bad%21/jobs", "x"); w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d", w.Code)
+	}
+	if w := do(g, "POST", "/tubes/bad%21/reserve", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d", w.Code)
+	}
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() { done <- do(g, "GET", "/stats", "") }()
+	select {
+	case w := <-done:
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"pid":"42"`) {
+			t.Fatalf("status %d body %q", w.Code, w.Body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stats after a bad tube name hung")
+	}
+	if got := conns[0].sent.String(); got != "stats\r\n" {
+		t.Fatalf("sent %#v", got)
+	}
+}
@@ -0,0 +1,96 @@
+package beanstalk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+)
+
+// DefaultGzipMaxSize is the largest body a GzipCodec decompresses
+// when its MaxSize is zero.
+const DefaultGzipMaxSize = 16 << 20
+
+var (
+	gzipMagic = []byte("\x00BSZ")
+	rawMagic  = []byte("\x00BSU")
+)
+
+// GzipCodec wraps another Codec, compressing encoded bodies of at
+// least Threshold bytes with gzip. Compressed and uncompressed bodies
+// are marked with different prefixes, so Unmarshal tells them apart
+// whatever the encoded bytes are. Unmarshal also accepts bodies with
+// neither prefix, as written without a GzipCodec.
+type GzipCodec struct {
+	Codec     Codec // if nil, RawCodec is used
+	Threshold int   // bodies shorter than this are left uncompressed
+	Level     int   // gzip compression level; zero means gzip.DefaultCompression
+
+	// MaxSize is the largest decompressed body Unmarshal accepts.
+	// If zero, DefaultGzipMaxSize is used.
+	MaxSize int
+}
+
+func (c GzipCodec) codec() Codec {
+	if c.Codec == nil {
+		return RawCodec{}
+	}
+	return c.Codec
+}
+
+func (c GzipCodec) maxSize() int {
+	if c.MaxSize == 0 {
+		return DefaultGzipMaxSize
+	}
+	return c.MaxSize
+}
+
+// Marshal encodes v with the wrapped Codec and compresses the result
+// if it is at least c.Threshold bytes long.
+func (c GzipCodec) Marshal(v interface{}) ([]byte, error) {
+	b, err := c.codec().Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < c.Threshold {
+		return append(rawMagic[:len(rawMagic):len(rawMagic)], b...), nil
+	}
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buf bytes.Buffer
+	buf.Write(gzipMagic)
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	w.Write(b)
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decompresses data if necessary and decodes it into v with
+// the wrapped Codec.
+func (c GzipCodec) Unmarshal(data []byte, v interface{}) error {
+	switch {
+	case bytes.HasPrefix(data, rawMagic):
+		data = data[len(rawMagic):]
+	case bytes.HasPrefix(data, gzipMagic):
+		r, err := gzip.NewReader(bytes.NewReader(data[len(gzipMagic):]))
+		if err != nil {
+			return err
+		}
+		max := c.maxSize()
+		data, err = io.ReadAll(io.LimitReader(r, int64(max)+1))
+		if err != nil {
+			return err
+		}
+		if len(data) > max {
+			return errors.New("decompressed body too large")
+		}
+	}
+	return c.codec().Unmarshal(data, v)
+}
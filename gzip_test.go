@@ -0,0 +1,82 @@
+package beanstalk
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGzipCodec(t *testing.T) {
+	in := strings.Repeat("a", 1000)
+	codec := GzipCodec{Threshold: 100}
+	b, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, gzipMagic) || len(b) >= len(in) {
+		t.Fatalf("expected compressed body, got %d bytes", len(b))
+	}
+	var out string
+	if err = codec.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Fatalf("expected %d bytes, got %d", len(in), len(out))
+	}
+}
+
+func TestGzipCodecBelowThreshold(t *testing.T) {
+	codec := GzipCodec{Codec: JSONCodec{}, Threshold: 100}
+	b, err := codec.Marshal(codecTestValue{"x", 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "\x00BSU"+`{"A":"x","B":1}` {
+		t.Fatalf("expected uncompressed body, got %#v", string(b))
+	}
+	var v codecTestValue
+	if err = codec.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v != (codecTestValue{"x", 1}) {
+		t.Fatalf("got %#v", v)
+	}
+}
+
+func TestGzipCodecCorrupt(t *testing.T) {
+	var out []byte
+	err := GzipCodec{}.Unmarshal(append(gzipMagic[:len(gzipMagic):len(gzipMagic)], "junk"...), &out)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestGzipCodecMagicBelowThreshold(t *testing.T) {
+	in := "\x00BSZ not compressed"
+	codec := GzipCodec{Threshold: 100}
+	b, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out string
+	if err = codec.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Fatalf("expected %#v, got %#v", in, out)
+	}
+}
+
+func TestGzipCodecTooLarge(t *testing.T) {
+	b, err := GzipCodec{}.Marshal(strings.Repeat("a", 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out string
+	if err = (GzipCodec{MaxSize: 999}).Unmarshal(b, &out); err == nil {
+		t.Fatal("expected error")
+	}
+	if err = (GzipCodec{MaxSize: 1000}).Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+}
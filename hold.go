@@ -0,0 +1,103 @@
+package beanstalk
+
+import (
+	"errors"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// Header names used by Holder.
+const (
+	HeaderDeliverAt  = "Deliver-At" // RFC 3339
+	HeaderTargetTube = "Target-Tube"
+	HeaderTargetTTR  = "Target-Ttr" // seconds
+)
+
+// A Holder schedules jobs further in the future than a single delay
+// can express. Such jobs wait in a holding tube, wrapped in an
+// Envelope naming their target tube and delivery time, and are
+// delayed again each time they become ready until they are close
+// enough to be put into their target tube.
+//
+// Some process must call Forward in a loop to move held jobs along.
+type Holder struct {
+	Tube *Tube // the holding tube
+
+	// MaxDelay is the longest delay used for held jobs.
+	// If zero, the package-level MaxDelay is used.
+	MaxDelay time.Duration
+}
+
+func (h *Holder) maxDelay() time.Duration {
+	if h.MaxDelay > 0 {
+		return h.MaxDelay
+	}
+	return MaxDelay
+}
+
+// PutAt puts a job into the tube named target, delaying it until time
+// at, as in Tube.PutAt. If at is too far away, the job is put into
+// the holding tube instead.
+func (h *Holder) PutAt(target string, body []byte, pri uint32, at time.Time, ttr time.Duration) (id uint64, err error) {
+	if err := checkName(target); err != nil {
+		return 0, err
+	}
+	if at.Sub(now()) <= h.maxDelay() {
+		return NewTube(h.Tube.Conn, target).PutAt(body, pri, at, ttr)
+	}
+	e := &Envelope{textproto.MIMEHeader{}, body}
+	e.Header.Set(HeaderDeliverAt, at.UTC().Format(time.RFC3339Nano))
+	e.Header.Set(HeaderTargetTube, target)
+	e.Header.Set(HeaderTargetTTR, dur(ttr).String())
+	return h.Tube.PutEnvelope(e, pri, h.maxDelay(), ttr)
+}
+
+// Forward reserves a job from the holding tube, waiting up to timeout,
+// and either delays it again or, if its delivery time is close
+// enough, puts it into its target tube and deletes it from the
+// holding tube. Held jobs that are not envelopes or have malformed
+// headers are buried.
+func (h *Holder) Forward(timeout time.Duration) error {
+	c := h.Tube.Conn
+	id, body, err := NewTubeSet(c, h.Tube.Name).Reserve(timeout)
+	if err != nil {
+		return err
+	}
+	pri, err := c.jobPri(id)
+	if err != nil {
+		return err
+	}
+	e, err := ParseEnvelope(body)
+	if err != nil {
+		c.Bury(id, pri)
+		return err
+	}
+	at, err := time.Parse(time.RFC3339Nano, e.Header.Get(HeaderDeliverAt))
+	if err != nil {
+		c.Bury(id, pri)
+		return errBadHeld(HeaderDeliverAt)
+	}
+	ttr, err := strconv.ParseUint(e.Header.Get(HeaderTargetTTR), 10, 32)
+	if err != nil {
+		c.Bury(id, pri)
+		return errBadHeld(HeaderTargetTTR)
+	}
+	name := e.Header.Get(HeaderTargetTube)
+	if checkName(name) != nil {
+		c.Bury(id, pri)
+		return errBadHeld(HeaderTargetTube)
+	}
+	if d := at.Sub(now()); d > h.maxDelay() {
+		return c.Release(id, pri, h.maxDelay())
+	}
+	target := NewTube(c, name)
+	if _, err = target.PutAt(e.Body, pri, at, time.Duration(ttr)*time.Second); err != nil {
+		return err
+	}
+	return c.Delete(id)
+}
+
+func errBadHeld(header string) error {
+	return errors.New("held job has bad " + header + " header")
+}
@@ -0,0 +1,115 @@
+package beanstalk
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestHolderPutAtNear(t *testing.T) {
+	t0 := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	setNow(t, t0)
+	c := NewConn(mock("use foo\r\nput 0 60 0 1\r\nx\r\n", "USING foo\r\nINSERTED 1\r\n"))
+	h := &Holder{Tube: NewTube(c, "hold"), MaxDelay: time.Hour}
+	id, err := h.PutAt("foo", []byte("x"), 0, t0.Add(time.Minute), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 {
+		t.Fatal("expected 1, got", id)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const heldBody = "\x00BSE\x01Deliver-At: 2000-01-01T03:00:00Z\r\n" +
+	"Enqueued-At: 2000-01-01T00:00:00Z\r\nTarget-Ttr: 5\r\nTarget-Tube: foo\r\n\r\nx"
+
+func TestHolderPutAtFar(t *testing.T) {
+	t0 := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	setNow(t, t0)
+	c := NewConn(mock(
+		"use hold\r\nput 0 3600 5 110\r\n"+heldBody+"\r\n",
+		"USING hold\r\nINSERTED 1\r\n",
+	))
+	h := &Holder{Tube: NewTube(c, "hold"), MaxDelay: time.Hour}
+	id, err := h.PutAt("foo", []byte("x"), 0, t0.Add(3*time.Hour), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 {
+		t.Fatal("expected 1, got", id)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHolderForwardAgain(t *testing.T) {
+	t0 := time.Date(2000, 1, 1, 1, 0, 0, 0, time.UTC)
+	setNow(t, t0)
+	c := NewConn(mock(
+		"watch hold\r\nignore default\r\nreserve-with-timeout 1\r\nstats-job 1\r\nrelease 1 7 3600\r\n",
+		"WATCHING 2\r\nWATCHING 1\r\nRESERVED 1 110\r\n"+heldBody+"\r\n"+
+			"OK 11\r\n---\npri: 7\n\r\nRELEASED\r\n",
+	))
+	h := &Holder{Tube: NewTube(c, "hold"), MaxDelay: time.Hour}
+	if err := h.Forward(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHolderForwardDeliver(t *testing.T) {
+	t0 := time.Date(2000, 1, 1, 2, 30, 0, 0, time.UTC)
+	setNow(t, t0)
+	c := NewConn(mock(
+		"watch hold\r\nignore default\r\nreserve-with-timeout 1\r\nstats-job 1\r\n"+
+			"use foo\r\nput 7 1800 5 1\r\nx\r\ndelete 1\r\n",
+		"WATCHING 2\r\nWATCHING 1\r\nRESERVED 1 110\r\n"+heldBody+"\r\n"+
+			"OK 11\r\n---\npri: 7\n\r\nUSING foo\r\nINSERTED 2\r\nDELETED\r\n",
+	))
+	h := &Holder{Tube: NewTube(c, "hold"), MaxDelay: time.Hour}
+	if err := h.Forward(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHolderForwardBadTarget(t *testing.T) {
+	body := "\x00BSE\x01Deliver-At: 2000-01-01T03:00:00Z\r\n" +
+		"Target-Ttr: 5\r\nTarget-Tube: bad!\r\n\r\nx"
+	c := NewConn(mock(
+		"watch hold\r\nignore default\r\nreserve-with-timeout 1\r\nstats-job 1\r\nbury 1 7\r\n",
+		fmt.Sprintf("WATCHING 2\r\nWATCHING 1\r\nRESERVED 1 %d\r\n%s\r\n", len(body), body)+
+			"OK 11\r\n---\npri: 7\n\r\nBURIED\r\n",
+	))
+	h := &Holder{Tube: NewTube(c, "hold"), MaxDelay: time.Hour}
+	if err := h.Forward(time.Second); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHolderForwardBadEnvelope(t *testing.T) {
+	c := NewConn(mock(
+		"watch hold\r\nignore default\r\nreserve-with-timeout 1\r\nstats-job 1\r\nbury 1 7\r\n",
+		"WATCHING 2\r\nWATCHING 1\r\nRESERVED 1 5\r\n\x00BSE\x01\r\n"+
+			"OK 11\r\n---\npri: 7\n\r\nBURIED\r\n",
+	))
+	h := &Holder{Tube: NewTube(c, "hold"), MaxDelay: time.Hour}
+	if err := h.Forward(time.Second); !errors.Is(err, ErrBadEnvelope) {
+		t.Fatal("expected ErrBadEnvelope, got", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
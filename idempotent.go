@@ -0,0 +1,57 @@
+package beanstalk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// HeaderIdempotencyKey names the header of an Envelope that identifies
+// the work a job asks for, for Idempotent.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// A Handler processes a reserved job.
+type Handler func(id uint64, body []byte) error
+
+// IdempotencyKey returns the key identifying the work in a job body:
+// its Idempotency-Key header if it is an Envelope with one, and
+// otherwise "sha256:" followed by the hex SHA-256 of its payload.
+func IdempotencyKey(body []byte) string {
+	if e, err := ParseEnvelope(body); err == nil {
+		if k := e.Header.Get(HeaderIdempotencyKey); k != "" {
+			return k
+		}
+		body = e.Body
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Idempotent returns a Handler that runs h on each job whose
+// IdempotencyKey is not recorded in store, and deletes the job if h
+// succeeds. It records the key before deleting the job, so if the
+// delete fails, or the job's TTR ran out while h was running, the job
+// is deleted without running h when it is reserved again.
+//
+// If h returns an error, nothing is recorded and the job is left
+// reserved for the caller to release or bury. h must not delete the
+// job itself. Jobs without an Idempotency-Key header are identified
+// by their payload, so distinct jobs with identical payloads are
+// treated as duplicates.
+func Idempotent(c *Conn, store DedupStore, h Handler) Handler {
+	return func(id uint64, body []byte) error {
+		key := IdempotencyKey(body)
+		_, done, err := store.Get(key)
+		if err != nil {
+			return err
+		}
+		if !done {
+			if err := h(id, body); err != nil {
+				return err
+			}
+			if err := store.Set(key, id); err != nil {
+				return err
+			}
+		}
+		return c.Delete(id)
+	}
+}
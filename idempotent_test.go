@@ -0,0 +1,65 @@
+package beanstalk
+
+import (
+	"errors"
+	"net/textproto"
+	"testing"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	e := &Envelope{Header: textproto.MIMEHeader{}, Body: []byte("hello")}
+	b, err := e.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const sum = "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if k := IdempotencyKey([]byte("hello")); k != sum {
+		t.Fatal("got", k)
+	}
+	if k := IdempotencyKey(b); k != sum {
+		t.Fatal("got", k, "for envelope")
+	}
+	e.Header.Set(HeaderIdempotencyKey, "order-7")
+	if b, err = e.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	if k := IdempotencyKey(b); k != "order-7" {
+		t.Fatal("got", k)
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	c := NewConn(mock(
+		"delete 1\r\ndelete 1\r\ndelete 4\r\n",
+		"NOT_FOUND\r\nDELETED\r\nDELETED\r\n",
+	))
+	var ran []uint64
+	fail := errors.New("fail")
+	h := Idempotent(c, NewMemoryDedup(10, 0), func(id uint64, body []byte) error {
+		ran = append(ran, id)
+		if string(body) == "bad" {
+			return fail
+		}
+		return nil
+	})
+	// Job 1 is done, but its TTR runs out before it is deleted, so it
+	// is reserved again.
+	if err := h(1, []byte("a")); !errors.Is(err, ErrNotFound) {
+		t.Fatal("expected ErrNotFound, got", err)
+	}
+	if err := h(1, []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := h(3, []byte("bad")); err != fail {
+		t.Fatal("expected fail, got", err)
+	}
+	if err := h(4, []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 3 || ran[0] != 1 || ran[1] != 3 || ran[2] != 4 {
+		t.Fatal("handler ran for", ran)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
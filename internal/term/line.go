@@ -0,0 +1,184 @@
+package term
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrInterrupt is returned by ReadLine when the user types Ctrl-C.
+var ErrInterrupt = errors.New("interrupt")
+
+// A LineEditor reads lines typed at a terminal in raw mode, with
+// Emacs-style editing keys, history, and tab completion.
+type LineEditor struct {
+	Prompt string
+
+	// Complete, if non-nil, is called when the user types Tab with
+	// the cursor at the end of the line. It returns the possible
+	// completed lines.
+	Complete func(line string) []string
+
+	in      *bufio.Reader
+	out     io.Writer
+	history []string
+}
+
+// NewLineEditor returns a LineEditor reading keys from in and echoing
+// to out, which should be a terminal in raw mode.
+func NewLineEditor(in io.Reader, out io.Writer, prompt string) *LineEditor {
+	return &LineEditor{Prompt: prompt, in: bufio.NewReader(in), out: out}
+}
+
+// ReadLine reads a line. It returns io.EOF if the user types Ctrl-D
+// on an empty line, and ErrInterrupt if the user types Ctrl-C.
+func (e *LineEditor) ReadLine() (string, error) {
+	var line []rune
+	pos := 0
+	hist := len(e.history)
+	e.redraw(line, pos)
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			io.WriteString(e.out, "\r\n")
+			s := string(line)
+			if s != "" && (len(e.history) == 0 || e.history[len(e.history)-1] != s) {
+				e.history = append(e.history, s)
+			}
+			return s, nil
+		case 3: // Ctrl-C
+			io.WriteString(e.out, "^C\r\n")
+			return "", ErrInterrupt
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(line)
+		case 2: // Ctrl-B
+			if pos > 0 {
+				pos--
+			}
+		case 6: // Ctrl-F
+			if pos < len(line) {
+				pos++
+			}
+		case 8, 127: // backspace
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case 11: // Ctrl-K
+			line = line[:pos]
+		case 21: // Ctrl-U
+			line, pos = line[pos:], 0
+		case 16: // Ctrl-P
+			line, pos, hist = e.recall(line, hist-1)
+		case 14: // Ctrl-N
+			line, pos, hist = e.recall(line, hist+1)
+		case '\t':
+			if pos == len(line) && e.Complete != nil {
+				line = []rune(e.complete(string(line)))
+				pos = len(line)
+			}
+		case 27: // escape sequence
+			switch e.readEscape() {
+			case "[A":
+				line, pos, hist = e.recall(line, hist-1)
+			case "[B":
+				line, pos, hist = e.recall(line, hist+1)
+			case "[C":
+				if pos < len(line) {
+					pos++
+				}
+			case "[D":
+				if pos > 0 {
+					pos--
+				}
+			case "[H", "OH", "[1~":
+				pos = 0
+			case "[F", "OF", "[4~":
+				pos = len(line)
+			case "[3~":
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			}
+		default:
+			if r >= ' ' {
+				line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+				pos++
+			}
+		}
+		e.redraw(line, pos)
+	}
+}
+
+// readEscape reads the rest of an escape sequence after ESC.
+func (e *LineEditor) readEscape() string {
+	var b strings.Builder
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return b.String()
+		}
+		b.WriteRune(r)
+		if b.Len() > 1 && (r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r == '~') {
+			return b.String()
+		}
+	}
+}
+
+// recall returns history entry i, or an empty line past the end.
+func (e *LineEditor) recall(line []rune, i int) ([]rune, int, int) {
+	switch {
+	case i < 0:
+		return line, len(line), 0
+	case i >= len(e.history):
+		return nil, 0, len(e.history)
+	}
+	l := []rune(e.history[i])
+	return l, len(l), i
+}
+
+func (e *LineEditor) complete(line string) string {
+	c := e.Complete(line)
+	if len(c) == 0 {
+		return line
+	}
+	p := c[0]
+	for _, s := range c[1:] {
+		for !strings.HasPrefix(s, p) {
+			p = p[:len(p)-1]
+		}
+	}
+	if len(c) > 1 && p == line {
+		io.WriteString(e.out, "\r\n")
+		for _, s := range c {
+			fmt.Fprintf(e.out, "%s\r\n", s)
+		}
+	}
+	if len(p) < len(line) {
+		return line
+	}
+	return p
+}
+
+func (e *LineEditor) redraw(line []rune, pos int) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.Prompt, string(line))
+	if n := len(line) - pos; n > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", n)
+	}
+}
@@ -0,0 +1,59 @@
+package term
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func readLines(t *testing.T, e *LineEditor) []string {
+	var lines []string
+	for {
+		s, err := e.ReadLine()
+		if err == io.EOF {
+			return lines
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, s)
+	}
+}
+
+func TestLineEditorEditing(t *testing.T) {
+	in := "helo\x1b[D\x1b[Dl\r" + // insert in the middle
+		"abc\x7f\x7fx\r" + // backspace
+		"foo\x01\x0b\r" + // Ctrl-A, Ctrl-K
+		"\x1b[A\x1b[A\r" + // history
+		"\x04"
+	e := NewLineEditor(strings.NewReader(in), io.Discard, "> ")
+	got := strings.Join(readLines(t, e), "|")
+	if exp := "hello|ax||hello"; got != exp {
+		t.Fatalf("expected %#v, got %#v", exp, got)
+	}
+}
+
+func TestLineEditorInterrupt(t *testing.T) {
+	e := NewLineEditor(strings.NewReader("abc\x03"), io.Discard, "> ")
+	if _, err := e.ReadLine(); err != ErrInterrupt {
+		t.Fatal("expected ErrInterrupt, got", err)
+	}
+}
+
+func TestLineEditorComplete(t *testing.T) {
+	words := []string{"stats", "stats-job", "stats-tube", "put"}
+	e := NewLineEditor(strings.NewReader("p\t\rst\t\t-t\t\r"), io.Discard, "> ")
+	e.Complete = func(line string) []string {
+		var c []string
+		for _, w := range words {
+			if strings.HasPrefix(w, line) {
+				c = append(c, w)
+			}
+		}
+		return c
+	}
+	got := strings.Join(readLines(t, e), "|")
+	if exp := "put|stats-tube"; got != exp {
+		t.Fatalf("expected %#v, got %#v", exp, got)
+	}
+}
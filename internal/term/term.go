@@ -0,0 +1,46 @@
+// Package term provides the minimal terminal handling needed by the
+// interactive commands: raw mode, window size, and a line editor.
+package term
+
+import (
+	"errors"
+)
+
+// ErrUnsupported is returned on platforms without terminal support.
+var ErrUnsupported = errors.New("terminal operations not supported on this platform")
+
+// State holds the terminal settings to restore after MakeRaw.
+type State struct {
+	termios termios
+}
+
+// IsTerminal reports whether fd refers to a terminal.
+func IsTerminal(fd int) bool {
+	_, err := getTermios(fd)
+	return err == nil
+}
+
+// MakeRaw puts the terminal fd into raw mode and returns its previous
+// state, to be passed to Restore.
+func MakeRaw(fd int) (*State, error) {
+	t, err := getTermios(fd)
+	if err != nil {
+		return nil, err
+	}
+	old := &State{t}
+	makeRaw(&t)
+	if err = setTermios(fd, t); err != nil {
+		return nil, err
+	}
+	return old, nil
+}
+
+// Restore returns the terminal fd to state s.
+func Restore(fd int, s *State) error {
+	return setTermios(fd, s.termios)
+}
+
+// Size returns the width and height of the terminal fd.
+func Size(fd int) (width, height int, err error) {
+	return getSize(fd)
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package term
+
+type termios struct{}
+
+func getTermios(fd int) (termios, error) { return termios{}, ErrUnsupported }
+func setTermios(fd int, t termios) error { return ErrUnsupported }
+func makeRaw(t *termios)                 {}
+
+func getSize(fd int) (width, height int, err error) { return 0, 0, ErrUnsupported }
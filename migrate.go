@@ -0,0 +1,204 @@
+package beanstalk
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// A Migration moves jobs from tubes on one server to the same tubes
+// on another. Each job is reserved on Src, put on Dst with its
+// priority, remaining delay, TTR, and state, then deleted from Src.
+type Migration struct {
+	Src, Dst *Conn
+	Tubes    []string
+
+	// Interval is the minimum time between moving consecutive jobs.
+	Interval time.Duration
+
+	// If DryRun is set, jobs are counted from the tubes' stats but
+	// not moved, and neither server is changed.
+	DryRun bool
+
+	// Journal, if set, names a file recording each step of each move.
+	// Running a Migration again with the same Journal first finishes
+	// moves interrupted between putting a job on Dst and deleting it
+	// from Src. Each entry is synced to disk before the next step.
+	Journal string
+}
+
+// A MigrationReport summarizes a run of a Migration.
+type MigrationReport struct {
+	// Moved counts the jobs moved from each tube, or that would be
+	// moved in a dry run.
+	Moved map[string]int
+
+	// Resumed counts interrupted moves finished from the journal.
+	Resumed int
+
+	// Uncertain lists the Src ids of jobs whose moves were interrupted
+	// before the journal recorded the put on Dst. Such jobs may now
+	// exist on both servers.
+	Uncertain []uint64
+}
+
+type journalEntry struct {
+	Step string `json:"step"` // "begin", "put", "done", or "uncertain"
+	Tube string `json:"tube"`
+	Src  uint64 `json:"src"`
+	Dst  uint64 `json:"dst,omitempty"`
+}
+
+// Run performs the migration until all tubes in m.Tubes are empty of
+// ready, delayed, and buried jobs, or ctx is done. The returned report
+// is valid even if err is not nil.
+func (m *Migration) Run(ctx context.Context) (report *MigrationReport, err error) {
+	report = &MigrationReport{Moved: make(map[string]int)}
+	if m.DryRun {
+		return report, m.count(report)
+	}
+	log := func(journalEntry) error { return nil }
+	if m.Journal != "" {
+		f, err := os.OpenFile(m.Journal, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o666)
+		if err != nil {
+			return report, err
+		}
+		defer f.Close()
+		enc := json.NewEncoder(f)
+		log = func(e journalEntry) error {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+			return f.Sync()
+		}
+		if err = m.resume(f, log, report); err != nil {
+			return report, err
+		}
+	}
+
+	var last time.Time
+	for _, name := range m.Tubes {
+		t := NewTube(m.Src, name)
+		for _, state := range []string{"ready", "delayed", "buried"} {
+			for {
+				if wait := m.Interval - time.Since(last); wait > 0 {
+					timer := time.NewTimer(wait)
+					select {
+					case <-ctx.Done():
+						timer.Stop()
+					case <-timer.C:
+					}
+				}
+				if err = ctx.Err(); err != nil {
+					return report, err
+				}
+				last = time.Now()
+				j, err := t.take(state)
+				if errors.Is(err, ErrNotFound) {
+					break
+				}
+				if err != nil {
+					return report, err
+				}
+				if err = log(journalEntry{"begin", name, j.ID, 0}); err != nil {
+					j.restore(m.Src)
+					return report, err
+				}
+				dst, err := j.Put(m.Dst)
+				if err != nil {
+					j.restore(m.Src)
+					return report, err
+				}
+				if err = log(journalEntry{"put", name, j.ID, dst}); err != nil {
+					return report, err
+				}
+				if err = m.Src.Delete(j.ID); err != nil {
+					return report, err
+				}
+				if err = log(journalEntry{"done", name, j.ID, dst}); err != nil {
+					return report, err
+				}
+				report.Moved[name]++
+			}
+		}
+	}
+	return report, nil
+}
+
+// count fills in report with the number of ready, delayed, and
+// buried jobs in each tube, without touching the jobs.
+func (m *Migration) count(report *MigrationReport) error {
+	for _, name := range m.Tubes {
+		stats, err := NewTube(m.Src, name).Stats()
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, state := range []string{"ready", "delayed", "buried"} {
+			n, _ := strconv.Atoi(stats["current-jobs-"+state])
+			report.Moved[name] += n
+		}
+	}
+	return nil
+}
+
+// resume reads the journal f and finishes interrupted moves,
+// recording its progress with log.
+func (m *Migration) resume(f *os.File, log func(journalEntry) error, report *MigrationReport) error {
+	last := make(map[uint64]journalEntry)
+	var order []uint64
+	br := bufio.NewReader(f)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				// we stopped while writing the last line;
+				// end it so later entries start on their own line
+				if _, err = f.Write(nl); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var e journalEntry
+		if json.Unmarshal(line, &e) != nil {
+			continue
+		}
+		if _, ok := last[e.Src]; !ok {
+			order = append(order, e.Src)
+		}
+		last[e.Src] = e
+	}
+	for _, id := range order {
+		e := last[id]
+		switch e.Step {
+		case "begin":
+			report.Uncertain = append(report.Uncertain, id)
+			e.Step = "uncertain"
+		case "put":
+			err := m.Src.Delete(id)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return err
+			}
+			report.Resumed++
+			report.Moved[e.Tube]++
+			e.Step = "done"
+		default:
+			continue
+		}
+		if err := log(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package beanstalk
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const migrateStats = "---\ntube: foo\nstate: ready\npri: 3\nttr: 10\n"
+
+func TestMigration(t *testing.T) {
+	src := NewConn(mock(
+		"use foo\r\npeek-ready\r\nstats-job 1\r\nreserve-job 1\r\ndelete 1\r\n"+
+			"peek-ready\r\npeek-delayed\r\npeek-buried\r\n",
+		"USING foo\r\nFOUND 1 1\r\nx\r\nOK 42\r\n"+migrateStats+"\r\nRESERVED 1 1\r\nx\r\nDELETED\r\n"+
+			"NOT_FOUND\r\nNOT_FOUND\r\nNOT_FOUND\r\n",
+	))
+	dst := NewConn(mock("use foo\r\nput 3 0 10 1\r\nx\r\n", "USING foo\r\nINSERTED 5\r\n"))
+	journal := filepath.Join(t.TempDir(), "journal")
+	m := &Migration{Src: src, Dst: dst, Tubes: []string{"foo"}, Journal: journal}
+	report, err := m.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Moved["foo"] != 1 {
+		t.Fatal("expected 1 moved, got", report.Moved)
+	}
+	b, err := os.ReadFile(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"step":"begin","tube":"foo","src":1}
+{"step":"put","tube":"foo","src":1,"dst":5}
+{"step":"done","tube":"foo","src":1,"dst":5}
+`
+	if string(b) != exp {
+		t.Fatalf("expected journal %#v, got %#v", exp, string(b))
+	}
+	for _, c := range []*Conn{src, dst} {
+		if err = c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrationLostRace(t *testing.T) {
+	// a consumer reserves job 1 between our peek and reserve-job
+	src := NewConn(mock(
+		"use foo\r\npeek-ready\r\nstats-job 1\r\nreserve-job 1\r\n"+
+			"peek-ready\r\nstats-job 2\r\nreserve-job 2\r\ndelete 2\r\n"+
+			"peek-ready\r\npeek-delayed\r\npeek-buried\r\n",
+		"USING foo\r\nFOUND 1 1\r\nx\r\nOK 42\r\n"+migrateStats+"\r\nNOT_FOUND\r\n"+
+			"FOUND 2 1\r\ny\r\nOK 42\r\n"+migrateStats+"\r\nRESERVED 2 1\r\ny\r\nDELETED\r\n"+
+			"NOT_FOUND\r\nNOT_FOUND\r\nNOT_FOUND\r\n",
+	))
+	dst := NewConn(mock("use foo\r\nput 3 0 10 1\r\ny\r\n", "USING foo\r\nINSERTED 5\r\n"))
+	m := &Migration{Src: src, Dst: dst, Tubes: []string{"foo"}}
+	report, err := m.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Moved["foo"] != 1 {
+		t.Fatal("expected 1 moved, got", report.Moved)
+	}
+	for _, c := range []*Conn{src, dst} {
+		if err = c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrationDryRun(t *testing.T) {
+	src := NewConn(mock(
+		"stats-tube foo\r\n",
+		"OK 98\r\n---\ncurrent-jobs-ready: 1\ncurrent-jobs-reserved: 4\ncurrent-jobs-delayed: 2\ncurrent-jobs-buried: 0\n\r\n",
+	))
+	dst := NewConn(mock("", ""))
+	m := &Migration{Src: src, Dst: dst, Tubes: []string{"foo"}, DryRun: true}
+	report, err := m.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Moved["foo"] != 3 {
+		t.Fatal("expected 3 moved, got", report.Moved)
+	}
+	for _, c := range []*Conn{src, dst} {
+		if err = c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrationResume(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "journal")
+	err := os.WriteFile(journal, []byte(`{"step":"begin","tube":"foo","src":1}
+{"step":"put","tube":"foo","src":1,"dst":5}
+{"step":"begin","tube":"foo","src":2}
+{"step":"begin","tube":"fo`), 0o666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := NewConn(mock(
+		"delete 1\r\nuse foo\r\npeek-ready\r\npeek-delayed\r\npeek-buried\r\n",
+		"NOT_FOUND\r\nUSING foo\r\nNOT_FOUND\r\nNOT_FOUND\r\nNOT_FOUND\r\n",
+	))
+	m := &Migration{Src: src, Dst: NewConn(mock("", "")), Tubes: []string{"foo"}, Journal: journal}
+	report, err := m.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Resumed != 1 || len(report.Uncertain) != 1 || report.Uncertain[0] != 2 {
+		t.Fatalf("got %#v", report)
+	}
+	b, err := os.ReadFile(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(b), `{"step":"begin","tube":"fo
+{"step":"done","tube":"foo","src":1,"dst":5}
+{"step":"uncertain","tube":"foo","src":2}
+`) {
+		t.Fatalf("got journal %#v", string(b))
+	}
+	if err = src.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,139 @@
+package beanstalk
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MirrorPolicy says what Mirror.Put does when its queue of copies is
+// full.
+type MirrorPolicy int
+
+const (
+	// MirrorDrop discards the copy and counts it in Dropped, so the
+	// shadow server never slows the primary.
+	MirrorDrop MirrorPolicy = iota
+
+	// MirrorBlock makes Put wait for room in the queue, so every job
+	// is copied but a slow shadow server slows producers.
+	MirrorBlock
+)
+
+// DefaultMirrorBuffer is the length of a Mirror's queue of copies
+// when the buffer passed to NewMirror is zero or negative.
+const DefaultMirrorBuffer = 1024
+
+// Mirror puts jobs as Tube.Put does, and also copies them to a shadow
+// connection, such as a staging server, in the background. The copy
+// is put after the primary put returns, so it does not add to Put's
+// latency, and a failure to copy does not affect the primary job.
+//
+// Tubes selects the tubes that are copied. It maps the name of a
+// primary tube to the name of its shadow tube; an empty shadow name
+// means the same name. If Tubes is nil, every tube is copied under its
+// own name. To copy to another tube on the primary server, use a
+// second Conn to that server as Shadow and rename the tubes.
+type Mirror struct {
+	Shadow *Conn
+	Tubes  map[string]string
+	Policy MirrorPolicy
+
+	// OnError, if non-nil, is called with each error putting a copy.
+	OnError func(error)
+
+	q       chan mirrorJob
+	done    chan struct{}
+	mu      sync.RWMutex // held for writing once closed
+	closed  bool
+	dropped uint64
+}
+
+type mirrorJob struct {
+	tube       string
+	body       []byte
+	pri        uint32
+	delay, ttr time.Duration
+}
+
+// NewMirror returns a Mirror copying to shadow with the given policy,
+// queueing up to buffer copies, and starts its background goroutine.
+func NewMirror(shadow *Conn, policy MirrorPolicy, buffer int) *Mirror {
+	if buffer <= 0 {
+		buffer = DefaultMirrorBuffer
+	}
+	m := &Mirror{
+		Shadow: shadow,
+		Policy: policy,
+		q:      make(chan mirrorJob, buffer),
+		done:   make(chan struct{}),
+	}
+	go m.run()
+	return m
+}
+
+func (m *Mirror) run() {
+	defer close(m.done)
+	for j := range m.q {
+		_, err := NewTube(m.Shadow, j.tube).Put(j.body, j.pri, j.delay, j.ttr)
+		if err != nil && m.OnError != nil {
+			m.OnError(err)
+		}
+	}
+}
+
+// Put puts a job into tube t as in Tube.Put, and queues a copy for the
+// shadow connection if t is selected. Nothing is copied if the primary
+// put fails or the Mirror is closed.
+func (m *Mirror) Put(t *Tube, body []byte, pri uint32, delay, ttr time.Duration) (id uint64, err error) {
+	id, err = t.Put(body, pri, delay, ttr)
+	if err != nil {
+		return 0, err
+	}
+	name, ok := t.Name, m.Tubes == nil
+	if s, found := m.Tubes[t.Name]; found {
+		ok = true
+		if s != "" {
+			name = s
+		}
+	}
+	if !ok {
+		return id, nil
+	}
+	// the caller may reuse body once Put returns
+	j := mirrorJob{name, append([]byte(nil), body...), pri, delay, ttr}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return id, nil
+	}
+	if m.Policy == MirrorBlock {
+		m.q <- j
+		return id, nil
+	}
+	select {
+	case m.q <- j:
+	default:
+		atomic.AddUint64(&m.dropped, 1)
+	}
+	return id, nil
+}
+
+// Dropped returns the number of copies discarded because the queue was
+// full.
+func (m *Mirror) Dropped() uint64 {
+	return atomic.LoadUint64(&m.dropped)
+}
+
+// Close stops copying new jobs and waits until the queued copies have
+// been put. It does not close Shadow.
+func (m *Mirror) Close() error {
+	m.mu.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.q)
+	}
+	m.mu.Unlock()
+	<-m.done
+	return nil
+}
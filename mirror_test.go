@@ -0,0 +1,125 @@
+package beanstalk
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMirror(t *testing.T) {
+	c := NewConn(mock(
+		"put 1 0 10 1\r\na\r\nuse foo\r\nput 2 5 10 1\r\nb\r\nuse bar\r\nput 3 0 10 1\r\nc\r\n",
+		"INSERTED 1\r\nUSING foo\r\nINSERTED 2\r\nUSING bar\r\nINSERTED 3\r\n",
+	))
+	shadow := NewConn(mock(
+		"use foo.shadow\r\nput 2 5 10 1\r\nb\r\n",
+		"USING foo.shadow\r\nINSERTED 9\r\n",
+	))
+	m := NewMirror(shadow, MirrorBlock, 0)
+	m.Tubes = map[string]string{"foo": "foo.shadow"}
+	for _, p := range []struct {
+		tube string
+		body string
+		pri  uint32
+	}{{"default", "a", 1}, {"foo", "b", 2}, {"bar", "c", 3}} {
+		delay := time.Duration(0)
+		if p.tube == "foo" {
+			delay = 5 * time.Second
+		}
+		id, err := m.Put(NewTube(c, p.tube), []byte(p.body), p.pri, delay, 10*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != uint64(p.pri) {
+			t.Fatalf("expected %d, got %d", p.pri, id)
+		}
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := shadow.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMirrorReusedBody(t *testing.T) {
+	c := NewConn(mock("put 0 0 10 1\r\na\r\n", "INSERTED 1\r\n"))
+	shadow := NewConn(mock("put 0 0 10 1\r\na\r\n", "INSERTED 9\r\n"))
+	m := NewMirror(shadow, MirrorBlock, -1)
+	body := []byte("a")
+	if _, err := m.Put(NewTube(c, "default"), body, 0, 0, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	body[0] = 'z'
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := shadow.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// stallWriter blocks its first write until release is closed.
+type stallWriter struct {
+	io.Reader
+	started chan bool
+	release chan bool
+	n       int
+}
+
+func (w *stallWriter) Write(b []byte) (int, error) {
+	if w.n++; w.n == 1 {
+		w.started <- true
+		<-w.release
+	}
+	return len(b), nil
+}
+
+func (w *stallWriter) Close() error { return nil }
+
+func TestMirrorDrop(t *testing.T) {
+	c := NewConn(mock(
+		"put 0 0 1 1\r\na\r\nput 0 0 1 1\r\nb\r\nput 0 0 1 1\r\nc\r\n",
+		"INSERTED 1\r\nINSERTED 2\r\nINSERTED 3\r\n",
+	))
+	w := &stallWriter{
+		Reader:  strings.NewReader("INSERTED 1\r\nINSERTED 2\r\n"),
+		started: make(chan bool),
+		release: make(chan bool),
+	}
+	var errs []error
+	m := NewMirror(NewConn(w), MirrorDrop, 1)
+	m.OnError = func(err error) { errs = append(errs, err) }
+	put := func(body string) {
+		if _, err := m.Put(&c.Tube, []byte(body), 0, 0, time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("a")
+	<-w.started // the copy of a is being sent
+	put("b")    // queued
+	put("c")    // dropped
+	if n := m.Dropped(); n != 1 {
+		t.Fatalf("expected 1 dropped, got %d", n)
+	}
+	close(w.release)
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if w.n != 2 {
+		t.Fatalf("expected 2 copies, got %d", w.n)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
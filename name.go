@@ -29,6 +29,11 @@ var (
 	ErrTooLong = errors.New("name is too long")
 )
 
+// CheckName returns a NameError if s is not a valid tube name.
+func CheckName(s string) error {
+	return checkName(s)
+}
+
 func checkName(s string) error {
 	switch {
 	case len(s) == 0:
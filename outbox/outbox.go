@@ -0,0 +1,264 @@
+// Package outbox implements the transactional outbox pattern for
+// beanstalkd: jobs are written to a table in the application's own
+// database transaction, and a Relay puts them on the server after the
+// transaction commits. A job is never put for a write that was rolled
+// back, and every committed job is put at least once.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+// Defaults for Outbox and Relay fields left zero.
+const (
+	DefaultTable    = "beanstalk_outbox"
+	DefaultBatch    = 100
+	DefaultInterval = time.Second
+)
+
+// A Dialect holds the SQL that differs between databases.
+type Dialect struct {
+	// Placeholder returns the query placeholder for the nth argument,
+	// counting from 1.
+	Placeholder func(n int) string
+
+	// ID is the column definition of an auto-incrementing primary key.
+	ID string
+
+	// Blob is the type of a binary column.
+	Blob string
+}
+
+func question(int) string { return "?" }
+func dollar(n int) string { return "$" + strconv.Itoa(n) }
+
+// Dialects for common databases.
+var (
+	SQLite   = Dialect{question, "INTEGER PRIMARY KEY AUTOINCREMENT", "BLOB"}
+	MySQL    = Dialect{question, "BIGINT AUTO_INCREMENT PRIMARY KEY", "LONGBLOB"}
+	Postgres = Dialect{dollar, "BIGSERIAL PRIMARY KEY", "BYTEA"}
+)
+
+// Outbox describes an outbox table. The zero value uses DefaultTable
+// and the SQLite dialect.
+type Outbox struct {
+	Table   string
+	Dialect Dialect
+}
+
+func (o *Outbox) table() string {
+	if o.Table == "" {
+		return DefaultTable
+	}
+	return o.Table
+}
+
+func (o *Outbox) dialect() Dialect {
+	if o.Dialect.Placeholder == nil {
+		return SQLite
+	}
+	return o.Dialect
+}
+
+// placeholders returns the placeholders for arguments from..to.
+func (o *Outbox) placeholders(from, to int) string {
+	p := make([]string, 0, to-from+1)
+	for n := from; n <= to; n++ {
+		p = append(p, o.dialect().Placeholder(n))
+	}
+	return strings.Join(p, ", ")
+}
+
+// Schema returns the statement that creates the outbox table. Rows
+// with a NULL sent_at are waiting to be put; job_id records the id the
+// server gave each job.
+func (o *Outbox) Schema() string {
+	d := o.dialect()
+	return fmt.Sprintf(`CREATE TABLE %s (
+	id %s,
+	tube VARCHAR(200) NOT NULL,
+	body %s NOT NULL,
+	pri BIGINT NOT NULL,
+	delay_seconds BIGINT NOT NULL,
+	ttr_seconds BIGINT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	sent_at TIMESTAMP NULL,
+	job_id BIGINT NULL
+)`, o.table(), d.ID, d.Blob)
+}
+
+// Execer is implemented by *sql.Tx, *sql.DB, and *sql.Conn.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Enqueue records a job to be put into the named tube. Pass the
+// transaction that makes the write the job is about; the job is put
+// only if it commits. Delays are whole seconds, counted from when
+// Enqueue is called.
+func (o *Outbox) Enqueue(ctx context.Context, tx Execer, tube string, body []byte, pri uint32, delay, ttr time.Duration) error {
+	// check the name now, so it fails here rather than in the Relay
+	if err := beanstalk.CheckName(tube); err != nil {
+		return err
+	}
+	q := fmt.Sprintf(
+		"INSERT INTO %s (tube, body, pri, delay_seconds, ttr_seconds, created_at) VALUES (%s)",
+		o.table(), o.placeholders(1, 6),
+	)
+	_, err := tx.ExecContext(ctx, q, tube, body, int64(pri),
+		int64(delay/time.Second), int64(ttr/time.Second), time.Now().UTC())
+	return err
+}
+
+// Relay puts the jobs in an outbox on a server, oldest first, and
+// marks them sent.
+//
+// A job is put again if the Relay stops between putting it and
+// marking it sent, so consumers should tolerate duplicates. Run one
+// Relay per outbox table; concurrent relays would put each job once
+// per relay.
+type Relay struct {
+	Outbox *Outbox
+	DB     *sql.DB
+	Conn   *beanstalk.Conn
+
+	// Batch is the most rows read per query. If zero, DefaultBatch is
+	// used.
+	Batch int
+
+	// Interval is the time Run waits after finding the outbox empty.
+	// If zero, DefaultInterval is used.
+	Interval time.Duration
+
+	// OnError, if non-nil, is called with the error for each job the
+	// server rejects, such as ErrJobTooBig. Such a job is marked sent
+	// with a NULL job_id so it does not block the jobs behind it.
+	OnError func(error)
+}
+
+// Once puts up to Batch unsent jobs and returns the number it put or
+// rejected.
+func (r *Relay) Once(ctx context.Context) (n int, err error) {
+	o := r.Outbox
+	batch := r.Batch
+	if batch <= 0 {
+		batch = DefaultBatch
+	}
+	rows, err := r.DB.QueryContext(ctx, fmt.Sprintf(
+		"SELECT id, tube, body, pri, delay_seconds, ttr_seconds, created_at FROM %s"+
+			" WHERE sent_at IS NULL ORDER BY id LIMIT %d",
+		o.table(), batch,
+	))
+	if err != nil {
+		return 0, err
+	}
+	var jobs []row
+	for rows.Next() {
+		var j row
+		if err := rows.Scan(&j.id, &j.tube, &j.body, &j.pri, &j.delay, &j.ttr, &j.created); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		jobs = append(jobs, j)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	update := fmt.Sprintf(
+		"UPDATE %s SET sent_at = %s, job_id = %s WHERE id = %s",
+		o.table(), o.dialect().Placeholder(1), o.dialect().Placeholder(2), o.dialect().Placeholder(3),
+	)
+	for _, j := range jobs {
+		id, err := r.put(j)
+		jobID := sql.NullInt64{Int64: int64(id), Valid: err == nil}
+		if err != nil {
+			if !rejected(err) {
+				return n, err
+			}
+			if r.OnError != nil {
+				r.OnError(err)
+			}
+		}
+		if _, err := r.DB.ExecContext(ctx, update, time.Now().UTC(), jobID, j.id); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Run calls Once until ctx is done, waiting Interval whenever the
+// outbox is empty or an error occurs. Errors are passed to OnError.
+func (r *Relay) Run(ctx context.Context) error {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	batch := r.Batch
+	if batch <= 0 {
+		batch = DefaultBatch
+	}
+	for {
+		n, err := r.Once(ctx)
+		if err != nil && r.OnError != nil && ctx.Err() == nil {
+			r.OnError(err)
+		}
+		if err == nil && n == batch {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Purge deletes the jobs that were marked sent before t.
+func (r *Relay) Purge(ctx context.Context, t time.Time) (int64, error) {
+	res, err := r.DB.ExecContext(ctx, fmt.Sprintf(
+		"DELETE FROM %s WHERE sent_at < %s", r.Outbox.table(), r.Outbox.dialect().Placeholder(1),
+	), t.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+type row struct {
+	id         int64
+	tube       string
+	body       []byte
+	pri        int64
+	delay, ttr int64
+	created    time.Time
+}
+
+// put puts j, shortening its delay by the time since it was enqueued.
+func (r *Relay) put(j row) (uint64, error) {
+	delay := time.Duration(j.delay)*time.Second - time.Since(j.created)
+	if delay < 0 {
+		delay = 0
+	}
+	return beanstalk.NewTube(r.Conn, j.tube).Put(j.body, uint32(j.pri), delay, time.Duration(j.ttr)*time.Second)
+}
+
+// rejected reports whether err is the server refusing a particular
+// job, rather than being unable to take jobs at all.
+func rejected(err error) bool {
+	var nerr beanstalk.NameError
+	return errors.Is(err, beanstalk.ErrJobTooBig) || errors.Is(err, beanstalk.ErrBadFormat) ||
+		errors.As(err, &nerr)
+}
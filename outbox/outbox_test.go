@@ -0,0 +1,281 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/beanstalkd/go-beanstalk"
+)
+
+// fakeDriver is an in-memory stand-in for a database, understanding
+// only the statements this package makes.
+type fakeDriver struct {
+	mu  sync.Mutex
+	dbs map[string]*fakeDB
+}
+
+type fakeDB struct {
+	mu     sync.Mutex
+	rows   []*fakeRow
+	nextID int64
+}
+
+type fakeRow struct {
+	id              int64
+	tube            string
+	body            []byte
+	pri, delay, ttr int64
+	created         time.Time
+	sent            *time.Time
+	jobID           driver.Value
+}
+
+var drv = &fakeDriver{dbs: make(map[string]*fakeDB)}
+
+func init() { sql.Register("outboxtest", drv) }
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dbs[name] == nil {
+		d.dbs[name] = &fakeDB{}
+	}
+	return &fakeDBConn{db: d.dbs[name]}, nil
+}
+
+type fakeDBConn struct {
+	db      *fakeDB
+	inTx    bool
+	pending []*fakeRow
+}
+
+func (c *fakeDBConn) Prepare(q string) (driver.Stmt, error) { return &fakeStmt{c, q}, nil }
+func (c *fakeDBConn) Close() error                          { return nil }
+func (c *fakeDBConn) Begin() (driver.Tx, error)             { c.inTx = true; return c, nil }
+
+func (c *fakeDBConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	for _, r := range c.pending {
+		c.db.nextID++
+		r.id = c.db.nextID
+		c.db.rows = append(c.db.rows, r)
+	}
+	c.pending, c.inTx = nil, false
+	return nil
+}
+
+func (c *fakeDBConn) Rollback() error {
+	c.pending, c.inTx = nil, false
+	return nil
+}
+
+type fakeStmt struct {
+	c *fakeDBConn
+	q string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	db := s.c.db
+	switch {
+	case strings.HasPrefix(s.q, "INSERT"):
+		r := &fakeRow{
+			tube:    args[0].(string),
+			body:    args[1].([]byte),
+			pri:     args[2].(int64),
+			delay:   args[3].(int64),
+			ttr:     args[4].(int64),
+			created: args[5].(time.Time),
+		}
+		if s.c.inTx {
+			s.c.pending = append(s.c.pending, r)
+			return driver.RowsAffected(1), nil
+		}
+		s.c.pending = []*fakeRow{r}
+		return driver.RowsAffected(1), s.c.Commit()
+	case strings.HasPrefix(s.q, "UPDATE"):
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		for _, r := range db.rows {
+			if r.id == args[2].(int64) {
+				t := args[0].(time.Time)
+				r.sent, r.jobID = &t, args[1]
+				return driver.RowsAffected(1), nil
+			}
+		}
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(s.q, "DELETE"):
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		var keep []*fakeRow
+		for _, r := range db.rows {
+			if r.sent == nil || !r.sent.Before(args[0].(time.Time)) {
+				keep = append(keep, r)
+			}
+		}
+		n := len(db.rows) - len(keep)
+		db.rows = keep
+		return driver.RowsAffected(n), nil
+	}
+	return nil, fmt.Errorf("unexpected statement %q", s.q)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	var limit int
+	if _, err := fmt.Sscanf(s.q[strings.LastIndex(s.q, "LIMIT"):], "LIMIT %d", &limit); err != nil {
+		return nil, fmt.Errorf("unexpected query %q", s.q)
+	}
+	db := s.c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var rows fakeRows
+	for _, r := range db.rows {
+		if r.sent == nil && len(rows) < limit {
+			rows = append(rows, []driver.Value{r.id, r.tube, r.body, r.pri, r.delay, r.ttr, r.created})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i][0].(int64) < rows[j][0].(int64) })
+	return &rows, nil
+}
+
+type fakeRows [][]driver.Value
+
+func (r *fakeRows) Columns() []string {
+	return []string{"id", "tube", "body", "pri", "delay_seconds", "ttr_seconds", "created_at"}
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(*r) == 0 {
+		return io.EOF
+	}
+	copy(dest, (*r)[0])
+	*r = (*r)[1:]
+	return nil
+}
+
+// fakeConn replies with a canned response and records what it is sent.
+type fakeConn struct {
+	io.Reader
+	sent bytes.Buffer
+}
+
+func (f *fakeConn) Write(b []byte) (int, error) { return f.sent.Write(b) }
+func (f *fakeConn) Close() error                { return nil }
+
+func openDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("outboxtest", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func enqueue(t *testing.T, db *sql.DB, o *Outbox, commit bool, tube, body string) {
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Enqueue(ctx, tx, tube, []byte(body), 5, 0, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if commit {
+		err = tx.Commit()
+	} else {
+		err = tx.Rollback()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	db := openDB(t)
+	o := &Outbox{}
+	enqueue(t, db, o, true, "foo", "a")
+	enqueue(t, db, o, false, "foo", "rolled back")
+	enqueue(t, db, o, true, "bar", "b")
+
+	f := &fakeConn{Reader: strings.NewReader("USING foo\r\nINSERTED 10\r\nUSING bar\r\nINSERTED 11\r\n")}
+	r := &Relay{Outbox: o, DB: db, Conn: beanstalk.NewConn(f)}
+	n, err := r.Once(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 jobs, got %d", n)
+	}
+	want := "use foo\r\nput 5 0 60 1\r\na\r\nuse bar\r\nput 5 0 60 1\r\nb\r\n"
+	if got := f.sent.String(); got != want {
+		t.Fatalf("sent %#v, want %#v", got, want)
+	}
+	rows := drv.dbs[t.Name()].rows
+	if len(rows) != 2 || rows[0].jobID != int64(10) || rows[1].jobID != int64(11) {
+		t.Fatalf("rows not marked sent: %+v %+v", rows[0], rows[1])
+	}
+	if n, err = r.Once(context.Background()); n != 0 || err != nil {
+		t.Fatalf("got %d, %v on empty outbox", n, err)
+	}
+
+	if n, err := r.Purge(context.Background(), time.Now().Add(time.Second)); n != 2 || err != nil {
+		t.Fatalf("purged %d, %v", n, err)
+	}
+}
+
+func TestRelayErrors(t *testing.T) {
+	db := openDB(t)
+	o := &Outbox{}
+	enqueue(t, db, o, true, "default", "too big")
+	enqueue(t, db, o, true, "default", "b")
+
+	// The server rejects the first job, then the connection drops.
+	f := &fakeConn{Reader: strings.NewReader("JOB_TOO_BIG\r\n")}
+	var rejected []error
+	r := &Relay{Outbox: o, DB: db, Conn: beanstalk.NewConn(f), OnError: func(err error) { rejected = append(rejected, err) }}
+	n, err := r.Once(context.Background())
+	if !errors.Is(err, io.EOF) {
+		t.Fatal("expected EOF, got", err)
+	}
+	if n != 1 || len(rejected) != 1 || !errors.Is(rejected[0], beanstalk.ErrJobTooBig) {
+		t.Fatalf("got %d, %v", n, rejected)
+	}
+	rows := drv.dbs[t.Name()].rows
+	if rows[0].sent == nil || rows[0].jobID != nil || rows[1].sent != nil {
+		t.Fatalf("rows %+v %+v", rows[0], rows[1])
+	}
+}
+
+func TestEnqueueBadTube(t *testing.T) {
+	var o Outbox
+	err := o.Enqueue(context.Background(), openDB(t), "no spaces", nil, 0, 0, time.Second)
+	if !errors.Is(err, beanstalk.ErrBadChar) {
+		t.Fatal("expected ErrBadChar, got", err)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	o := Outbox{Table: "jobs", Dialect: Postgres}
+	s := o.Schema()
+	if !strings.HasPrefix(s, "CREATE TABLE jobs (") || !strings.Contains(s, "id BIGSERIAL PRIMARY KEY") || !strings.Contains(s, "body BYTEA NOT NULL") {
+		t.Fatal(s)
+	}
+	if got := o.placeholders(1, 3); got != "$1, $2, $3" {
+		t.Fatal(got)
+	}
+}
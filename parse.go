@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func parseDict(dat []byte) map[string]string {
@@ -52,3 +53,47 @@ func parseSize(s string) (string, int, error) {
 	}
 	return s[:i], n, nil
 }
+
+// jobInfo holds the stats-job fields used within the package.
+type jobInfo struct {
+	tube     string
+	state    string
+	pri      uint32
+	age      time.Duration
+	delay    time.Duration
+	ttr      time.Duration
+	timeLeft time.Duration
+	reserves int
+}
+
+func parseJobInfo(d map[string]string) (jobInfo, error) {
+	info := jobInfo{tube: d["tube"], state: d["state"]}
+	pri, err := strconv.ParseUint(d["pri"], 10, 32)
+	if err != nil {
+		return jobInfo{}, err
+	}
+	info.pri = uint32(pri)
+	for _, f := range []struct {
+		key string
+		p   *time.Duration
+	}{
+		{"age", &info.age},
+		{"delay", &info.delay},
+		{"ttr", &info.ttr},
+		{"time-left", &info.timeLeft},
+	} {
+		if v, ok := d[f.key]; ok {
+			n, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				return jobInfo{}, err
+			}
+			*f.p = time.Duration(n) * time.Second
+		}
+	}
+	if v, ok := d["reserves"]; ok {
+		if info.reserves, err = strconv.Atoi(v); err != nil {
+			return jobInfo{}, err
+		}
+	}
+	return info, nil
+}
@@ -3,6 +3,7 @@ package beanstalk
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestParseDict(t *testing.T) {
@@ -46,3 +47,22 @@ func TestParseListNil(t *testing.T) {
 		t.Fatalf("got %v", l)
 	}
 }
+
+func TestParseJobInfo(t *testing.T) {
+	info, err := parseJobInfo(parseDict([]byte(
+		"---\nid: 1\ntube: foo\nstate: delayed\npri: 7\nage: 3\ndelay: 10\nttr: 60\ntime-left: 5\nreserves: 2\n",
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := jobInfo{"foo", "delayed", 7, 3 * time.Second, 10 * time.Second, time.Minute, 5 * time.Second, 2}
+	if info != exp {
+		t.Fatalf("expected %#v, got %#v", exp, info)
+	}
+}
+
+func TestParseJobInfoBad(t *testing.T) {
+	if _, err := parseJobInfo(map[string]string{"pri": "x"}); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,325 @@
+package beanstalk
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultProxyMaxJobSize is the largest body a Proxy relays when
+// Proxy.MaxJobSize is zero.
+const DefaultProxyMaxJobSize = 16 << 20
+
+// DeniedResponse is sent in place of the server's response to a command
+// refused by a Proxy rule.
+const DeniedResponse = "UNKNOWN_COMMAND"
+
+// ProxyEvent records one command passed through a Proxy.
+type ProxyEvent struct {
+	Time     time.Time
+	Client   string   // client's network address
+	Command  string   // command name, such as "delete"
+	Args     []string // command arguments
+	BodySize int      // size of the job body sent by put
+	Tube     string   // tube the command acts on, if known
+	Denied   bool     // refused by a rule and not sent to the server
+	Response string   // response line, without any body
+	Err      error    // server error named by Response, if any
+	Duration time.Duration
+}
+
+// ProxyRule allows or denies commands. A rule matches a command if
+// Commands is empty or contains its name, and Tubes is empty or has a
+// pattern, in the syntax of path.Match, matching its tube. A command
+// whose tube is not known matches only rules with empty Tubes.
+type ProxyRule struct {
+	Allow    bool
+	Commands []string
+	Tubes    []string
+}
+
+func (r ProxyRule) match(ev *ProxyEvent) bool {
+	if len(r.Commands) > 0 && !contains(r.Commands, ev.Command) {
+		return false
+	}
+	if len(r.Tubes) == 0 {
+		return true
+	}
+	for _, pat := range r.Tubes {
+		if ok, _ := path.Match(pat, ev.Tube); ok && ev.Tube != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Proxy relays the protocol between clients and a server, reporting
+// each command to Log and refusing those denied by Rules.
+//
+// Rules are checked in order and the first that matches a command
+// decides it; a command matching no rule is allowed unless DenyByDefault
+// is set. A denied command gets DeniedResponse and the server never
+// sees it.
+//
+// A command's tube is the tube it names, such as the argument of watch
+// or pause-tube; the tube in use, for put, kick, and the peek commands;
+// and for delete, release, bury, touch, kick-job, reserve-job, peek,
+// and stats-job, the job's tube, which the proxy looks up with
+// stats-job before sending the command.
+// Reserve has no single tube; restrict it by denying watch.
+type Proxy struct {
+	// Dial opens a connection to the server for each client.
+	Dial func() (net.Conn, error)
+
+	Rules         []ProxyRule
+	DenyByDefault bool
+
+	// MaxJobSize is the largest job body or response body relayed, in
+	// bytes. A client putting a larger job gets JOB_TOO_BIG and is
+	// disconnected. If zero, DefaultProxyMaxJobSize is used.
+	MaxJobSize int
+
+	// Log, if non-nil, is called after each command.
+	Log func(ProxyEvent)
+}
+
+// Serve accepts clients on l and serves each in its own goroutine,
+// until Accept fails.
+func (p *Proxy) Serve(l net.Listener) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer c.Close()
+			p.ServeConn(c)
+		}()
+	}
+}
+
+// ServeConn relays commands from client to a new server connection
+// until either side closes. It returns nil if the client quits or
+// disconnects between commands.
+func (p *Proxy) ServeConn(client net.Conn) error {
+	server, err := p.Dial()
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+	s := proxySession{
+		p:    p,
+		addr: client.RemoteAddr().String(),
+		cr:   bufio.NewReader(client),
+		cw:   bufio.NewWriter(client),
+		sr:   bufio.NewReader(server),
+		sw:   bufio.NewWriter(server),
+		used: "default",
+	}
+	for {
+		err := s.relay()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type proxySession struct {
+	p      *Proxy
+	addr   string
+	cr, sr *bufio.Reader
+	cw, sw *bufio.Writer
+	used   string
+}
+
+// jobCommands are the commands whose tube is that of the job they name.
+var jobCommands = map[string]bool{
+	"delete":      true,
+	"release":     true,
+	"bury":        true,
+	"touch":       true,
+	"kick-job":    true,
+	"reserve-job": true,
+	"peek":        true,
+	"stats-job":   true,
+}
+
+// relay handles one command.
+func (s *proxySession) relay() error {
+	line, err := readLine(s.cr)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		fields = []string{""}
+	}
+	ev := ProxyEvent{
+		Time:    now(),
+		Client:  s.addr,
+		Command: fields[0],
+		Args:    fields[1:],
+	}
+	if ev.Command == "quit" {
+		return io.EOF
+	}
+	var body []byte
+	if ev.Command == "put" {
+		if _, n, err := parseSize(line); err == nil {
+			if n < 0 || n > s.p.maxJobSize() {
+				ev.Response = "JOB_TOO_BIG"
+				ev.Err = ErrJobTooBig
+				s.log(ev)
+				s.cw.WriteString("JOB_TOO_BIG\r\n")
+				s.cw.Flush()
+				return ErrJobTooBig
+			}
+			body = make([]byte, n+2) // include trailing CR NL
+			if _, err := io.ReadFull(s.cr, body); err != nil {
+				return err
+			}
+			ev.BodySize = n
+		}
+	}
+	switch {
+	case jobCommands[ev.Command] && len(ev.Args) > 0:
+		if ev.Tube, err = s.jobTube(ev.Args[0]); err != nil {
+			return err
+		}
+	case ev.Command == "use" || ev.Command == "watch" || ev.Command == "ignore" ||
+		ev.Command == "pause-tube" || ev.Command == "stats-tube":
+		if len(ev.Args) > 0 {
+			ev.Tube = ev.Args[0]
+		}
+	case ev.Command == "put" || ev.Command == "kick" || strings.HasPrefix(ev.Command, "peek-"):
+		ev.Tube = s.used
+	}
+
+	if !s.p.allowed(&ev) {
+		ev.Denied = true
+		ev.Response = DeniedResponse
+		ev.Err = ErrUnknown
+		s.log(ev)
+		s.cw.WriteString(DeniedResponse + "\r\n")
+		return s.cw.Flush()
+	}
+
+	s.sw.WriteString(line + "\r\n")
+	s.sw.Write(body)
+	if err := s.sw.Flush(); err != nil {
+		return err
+	}
+	resp, rbody, err := s.readResp()
+	if err != nil {
+		return err
+	}
+	s.cw.WriteString(resp + "\r\n")
+	s.cw.Write(rbody)
+	if err := s.cw.Flush(); err != nil {
+		return err
+	}
+
+	ev.Response = resp
+	ev.Err = respError[strings.SplitN(resp, " ", 2)[0]]
+	if ev.Command == "use" && strings.HasPrefix(resp, "USING ") {
+		s.used = strings.TrimPrefix(resp, "USING ")
+	}
+	ev.Duration = now().Sub(ev.Time)
+	s.log(ev)
+	return nil
+}
+
+// readResp reads a response from the server, including any body and
+// its trailing CR NL.
+func (s *proxySession) readResp() (line string, body []byte, err error) {
+	line, err = readLine(s.sr)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", nil, err
+	}
+	switch strings.SplitN(line, " ", 2)[0] {
+	case "RESERVED", "FOUND", "OK":
+		_, n, err := parseSize(line)
+		if err != nil {
+			return "", nil, err
+		}
+		if n < 0 || n > s.p.maxJobSize() {
+			return "", nil, ErrJobTooBig
+		}
+		body = make([]byte, n+2)
+		if _, err = io.ReadFull(s.sr, body); err != nil {
+			return "", nil, err
+		}
+	}
+	return line, body, nil
+}
+
+// jobTube asks the server for the tube of job id. It returns "" if the
+// job does not exist.
+func (s *proxySession) jobTube(id string) (string, error) {
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return "", nil
+	}
+	s.sw.WriteString("stats-job " + id + "\r\n")
+	if err := s.sw.Flush(); err != nil {
+		return "", err
+	}
+	resp, body, err := s.readResp()
+	if err != nil || !strings.HasPrefix(resp, "OK ") {
+		return "", err
+	}
+	return parseDict(body[:len(body)-2])["tube"], nil
+}
+
+func (p *Proxy) maxJobSize() int {
+	if p.MaxJobSize == 0 {
+		return DefaultProxyMaxJobSize
+	}
+	return p.MaxJobSize
+}
+
+func (p *Proxy) allowed(ev *ProxyEvent) bool {
+	for _, r := range p.Rules {
+		if r.match(ev) {
+			return r.Allow
+		}
+	}
+	return !p.DenyByDefault
+}
+
+func (s *proxySession) log(ev ProxyEvent) {
+	if s.p.Log != nil {
+		s.p.Log(ev)
+	}
+}
+
+// readLine reads a line terminated by CR NL or NL, without the
+// terminator.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
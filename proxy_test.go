@@ -0,0 +1,134 @@
+package beanstalk
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// mockConn is a net.Conn using mock for I/O.
+type mockConn struct {
+	net.Conn
+	io.ReadWriteCloser
+}
+
+func (m mockConn) Read(b []byte) (int, error)  { return m.ReadWriteCloser.Read(b) }
+func (m mockConn) Write(b []byte) (int, error) { return m.ReadWriteCloser.Write(b) }
+func (m mockConn) Close() error                { return m.ReadWriteCloser.Close() }
+func (m mockConn) RemoteAddr() net.Addr        { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234} }
+
+// proxyTest runs a Proxy between a client and a server. The server
+// expects to receive recv and replies with send; the client sends req
+// and expects resp.
+func proxyTest(t *testing.T, p *Proxy, recv, send, req, resp string) {
+	t.Helper()
+	server := mock(recv, send)
+	client := mock(resp, req)
+	p.Dial = func() (net.Conn, error) { return mockConn{nil, server}, nil }
+	if err := p.ServeConn(mockConn{nil, client}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProxyLog(t *testing.T) {
+	var events []ProxyEvent
+	p := &Proxy{Log: func(ev ProxyEvent) { events = append(events, ev) }}
+	proxyTest(t, p,
+		"use foo\r\nput 0 0 10 2\r\nhi\r\nstats-job 3\r\ndelete 3\r\nstats-job 3\r\npeek 3\r\n",
+		"USING foo\r\nINSERTED 3\r\nOK 16\r\n---\ntube: other\n\r\nNOT_FOUND\r\n"+
+			"OK 16\r\n---\ntube: other\n\r\nFOUND 3 2\r\nhi\r\n",
+		"use foo\r\nput 0 0 10 2\r\nhi\r\ndelete 3\r\npeek 3\r\n",
+		"USING foo\r\nINSERTED 3\r\nNOT_FOUND\r\nFOUND 3 2\r\nhi\r\n",
+	)
+	if len(events) != 4 {
+		t.Fatalf("got %d events", len(events))
+	}
+	if ev := events[1]; ev.Command != "put" || ev.Tube != "foo" || ev.BodySize != 2 || ev.Response != "INSERTED 3" {
+		t.Fatalf("put event %+v", ev)
+	}
+	if ev := events[2]; ev.Command != "delete" || ev.Tube != "other" || !errors.Is(ev.Err, ErrNotFound) {
+		t.Fatalf("delete event %+v", ev)
+	}
+	if ev := events[3]; ev.Err != nil || ev.Tube != "other" {
+		t.Fatalf("peek event %+v", ev)
+	}
+}
+
+func TestProxyRules(t *testing.T) {
+	var denied []string
+	p := &Proxy{
+		Rules: []ProxyRule{
+			{Allow: true, Commands: []string{"delete"}, Tubes: []string{"mine"}},
+			{Commands: []string{"delete", "kick", "kick-job", "reserve-job"}, Tubes: []string{"shared*"}},
+			{Commands: []string{"delete"}},
+		},
+		Log: func(ev ProxyEvent) {
+			if ev.Denied {
+				denied = append(denied, ev.Command+" "+ev.Tube)
+			}
+		},
+	}
+	proxyTest(t, p,
+		"stats-job 1\r\ndelete 1\r\nstats-job 2\r\nstats-job 9\r\nstats-job 2\r\nuse shared-a\r\nput 0 0 1 0\r\n\r\n",
+		"OK 15\r\n---\ntube: mine\n\r\nDELETED\r\nOK 19\r\n---\ntube: shared-b\n\r\nNOT_FOUND\r\n"+
+			"OK 19\r\n---\ntube: shared-b\n\r\nUSING shared-a\r\nINSERTED 4\r\n",
+		"delete 1\r\ndelete 2\r\ndelete 9\r\nreserve-job 2\r\nuse shared-a\r\nkick 5\r\nput 0 0 1 0\r\n\r\n",
+		"DELETED\r\nUNKNOWN_COMMAND\r\nUNKNOWN_COMMAND\r\nUNKNOWN_COMMAND\r\nUSING shared-a\r\nUNKNOWN_COMMAND\r\nINSERTED 4\r\n",
+	)
+	want := "delete shared-b,delete ,reserve-job shared-b,kick shared-a"
+	if got := strings.Join(denied, ","); got != want {
+		t.Fatalf("denied %q, want %q", got, want)
+	}
+}
+
+func TestProxyPeekRules(t *testing.T) {
+	p := &Proxy{
+		Rules: []ProxyRule{
+			{Commands: []string{"peek", "peek-ready"}, Tubes: []string{"payroll*"}},
+		},
+	}
+	proxyTest(t, p,
+		"stats-job 42\r\nstats-job 7\r\npeek 7\r\n",
+		"OK 18\r\n---\ntube: payroll\n\r\nOK 16\r\n---\ntube: other\n\r\nFOUND 7 1\r\nx\r\n",
+		"peek 42\r\npeek 7\r\n",
+		"UNKNOWN_COMMAND\r\nFOUND 7 1\r\nx\r\n",
+	)
+}
+
+func TestProxyJobTooBig(t *testing.T) {
+	for _, size := range []string{"9223372036854775807", "-5", "11"} {
+		server := mock("", "")
+		client := mock("JOB_TOO_BIG\r\n", "put 0 0 1 "+size+"\r\n")
+		p := &Proxy{
+			Dial:       func() (net.Conn, error) { return mockConn{nil, server}, nil },
+			MaxJobSize: 10,
+		}
+		if err := p.ServeConn(mockConn{nil, client}); err != ErrJobTooBig {
+			t.Fatalf("size %s: expected ErrJobTooBig, got %v", size, err)
+		}
+		if err := client.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("a\r\nb\nc"))
+	for _, want := range []string{"a", "b"} {
+		if got, err := readLine(r); err != nil || got != want {
+			t.Fatalf("got %q, %v, want %q", got, err, want)
+		}
+	}
+	if _, err := readLine(r); err != io.ErrUnexpectedEOF {
+		t.Fatal("expected ErrUnexpectedEOF, got", err)
+	}
+}
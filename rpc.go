@@ -0,0 +1,247 @@
+package beanstalk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Header names used by RPCClient and RPCServer, along with
+// Correlation-Id.
+const (
+	HeaderReplyTo   = "Reply-To"   // tube to put the reply in
+	HeaderRPCMethod = "Rpc-Method" // name of the handler to call
+	HeaderRPCError  = "Rpc-Error"  // error returned by the handler
+)
+
+// Defaults for RPCClient and RPCServer fields left zero.
+const (
+	DefaultRPCTTR         = time.Minute
+	DefaultRPCReplyPrefix = "rpc.reply."
+)
+
+// RPCError is an error returned by a remote handler.
+type RPCError string
+
+func (e RPCError) Error() string {
+	return "rpc: " + string(e)
+}
+
+// ErrNoReplyTo indicates a request without a Reply-To header.
+var ErrNoReplyTo = errors.New("rpc request has no reply tube")
+
+// newCorrelationID returns a random id; tests replace it.
+var newCorrelationID = newBlobKey
+
+// RPCClient calls methods served by an RPCServer. Each request is an
+// Envelope put into Tube; the reply is reserved from ReplyTube, which
+// should be used by no other client. Calls on one RPCClient are made
+// one at a time.
+type RPCClient struct {
+	Tube      *Tube
+	ReplyTube string
+
+	// Pri is the priority of requests.
+	Pri uint32
+
+	// TTR is the TTR of requests. If zero, DefaultRPCTTR is used.
+	TTR time.Duration
+
+	mu sync.Mutex
+}
+
+// NewRPCClient returns an RPCClient putting requests into the named
+// tube on c, with a new, randomly named reply tube. c should not be
+// used for anything else while a call is waiting.
+func NewRPCClient(c *Conn, tube string) (*RPCClient, error) {
+	key, err := newBlobKey()
+	if err != nil {
+		return nil, err
+	}
+	return &RPCClient{Tube: NewTube(c, tube), ReplyTube: DefaultRPCReplyPrefix + key}, nil
+}
+
+// Call asks the server to run method on body and waits up to timeout,
+// rounded up to whole seconds, for the reply. It returns ErrTimeout if
+// none arrives; the request is deleted if no server has reserved it
+// yet. An error returned by the remote handler is returned as an
+// RPCError. Replies to earlier calls that timed out are discarded.
+func (cl *RPCClient) Call(method string, body []byte, timeout time.Duration) ([]byte, error) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	corr, err := newCorrelationID()
+	if err != nil {
+		return nil, err
+	}
+	ttr := cl.TTR
+	if ttr == 0 {
+		ttr = DefaultRPCTTR
+	}
+	h := textproto.MIMEHeader{}
+	h.Set(HeaderRPCMethod, method)
+	h.Set(HeaderReplyTo, cl.ReplyTube)
+	h.Set(HeaderCorrelationID, corr)
+	reqID, err := cl.Tube.PutEnvelope(&Envelope{h, body}, cl.Pri, 0, ttr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cl.Tube.Conn
+	replies := NewTubeSet(c, cl.ReplyTube)
+	deadline := time.Now().Add(timeout)
+	for {
+		wait := time.Until(deadline)
+		if wait < 0 {
+			wait = 0
+		}
+		wait = (wait + time.Second - 1) / time.Second * time.Second
+		id, e, err := replies.ReserveEnvelope(wait)
+		if errors.Is(err, ErrTimeout) {
+			if err := c.Delete(reqID); err != nil && !errors.Is(err, ErrNotFound) {
+				return nil, err
+			}
+			return nil, err
+		}
+		if errors.Is(err, ErrDeadline) {
+			time.Sleep(deadlineBackoff)
+			continue
+		}
+		if id != 0 {
+			if derr := c.Delete(id); err == nil {
+				err = derr
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e.Header.Get(HeaderCorrelationID) != corr {
+			continue // a late reply to an earlier call
+		}
+		if msg := e.Header.Get(HeaderRPCError); msg != "" {
+			return nil, RPCError(msg)
+		}
+		return e.Body, nil
+	}
+}
+
+// An RPCHandler computes the reply to a request body.
+type RPCHandler func(body []byte) ([]byte, error)
+
+// RPCServer reserves requests from a set of tubes, runs the handler
+// registered for each request's method, and puts the reply, or the
+// handler's error, into the request's reply tube.
+type RPCServer struct {
+	Tubes *TubeSet
+
+	// TTR is the TTR of replies. If zero, DefaultRPCTTR is used.
+	TTR time.Duration
+
+	// OnError, if non-nil, is called with errors from requests that
+	// cannot be answered, such as those with a missing or invalid
+	// Reply-To header, which are buried.
+	OnError func(error)
+
+	mu       sync.RWMutex
+	handlers map[string]RPCHandler
+}
+
+// NewRPCServer returns an RPCServer reserving requests from the named
+// tubes on c.
+func NewRPCServer(c *Conn, tubes ...string) *RPCServer {
+	return &RPCServer{Tubes: NewTubeSet(c, tubes...), handlers: make(map[string]RPCHandler)}
+}
+
+// Handle registers h as the handler for method.
+func (s *RPCServer) Handle(method string, h RPCHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = h
+}
+
+// Serve answers requests until ctx is done or an error other than one
+// from a single request occurs. Requests are handled one at a time.
+func (s *RPCServer) Serve(ctx context.Context) error {
+	for ctx.Err() == nil {
+		id, e, err := s.Tubes.ReserveEnvelope(DefaultConsumeTimeout)
+		if errors.Is(err, ErrTimeout) {
+			continue
+		}
+		if errors.Is(err, ErrDeadline) {
+			select {
+			case <-time.After(deadlineBackoff):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		if err == nil {
+			err = checkReplyTo(e.Header.Get(HeaderReplyTo))
+		}
+		if err != nil && id != 0 {
+			err = s.bury(id, err)
+		} else if err == nil {
+			err = s.serve(id, e)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// checkReplyTo checks the Reply-To header of a request, so a request
+// that can't be answered is buried before its handler runs.
+func checkReplyTo(tube string) error {
+	if tube == "" {
+		return ErrNoReplyTo
+	}
+	return checkName(tube)
+}
+
+// serve runs the handler for request id and puts the reply.
+func (s *RPCServer) serve(id uint64, req *Envelope) error {
+	method := req.Header.Get(HeaderRPCMethod)
+	s.mu.RLock()
+	h := s.handlers[method]
+	s.mu.RUnlock()
+	var body []byte
+	err := fmt.Errorf("unknown method %q", method)
+	if h != nil {
+		body, err = h(req.Body)
+	}
+	rh := textproto.MIMEHeader{}
+	rh.Set(HeaderCorrelationID, req.Header.Get(HeaderCorrelationID))
+	if err != nil {
+		rh.Set(HeaderRPCError, strings.Join(strings.Fields(err.Error()), " "))
+		body = nil
+	}
+	ttr := s.TTR
+	if ttr == 0 {
+		ttr = DefaultRPCTTR
+	}
+	reply := NewTube(s.Tubes.Conn, req.Header.Get(HeaderReplyTo))
+	_, err = reply.PutEnvelope(&Envelope{rh, body}, 0, 0, ttr)
+	if errors.Is(err, ErrJobTooBig) {
+		return s.bury(id, err)
+	}
+	if err != nil {
+		return err
+	}
+	return s.Tubes.Conn.Delete(id)
+}
+
+// bury buries the request id after reporting why it cannot be
+// answered.
+func (s *RPCServer) bury(id uint64, reason error) error {
+	if s.OnError != nil {
+		s.OnError(reason)
+	}
+	pri, err := s.Tubes.Conn.jobPri(id)
+	if err != nil {
+		return err
+	}
+	return s.Tubes.Conn.Bury(id, pri)
+}
@@ -0,0 +1,120 @@
+package beanstalk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// envelopeResp returns a RESERVED response for job id with an envelope
+// body carrying the given header pairs and payload.
+func envelopeResp(t *testing.T, id uint64, body string, kv ...string) string {
+	h := textproto.MIMEHeader{}
+	for i := 0; i < len(kv); i += 2 {
+		h.Set(kv[i], kv[i+1])
+	}
+	b, err := (&Envelope{h, []byte(body)}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("RESERVED %d %d\r\n%s\r\n", id, len(b), b)
+}
+
+func TestRPCClient(t *testing.T) {
+	ids := []string{"c1", "c2", "c3"}
+	newCorrelationID = func() (string, error) {
+		id := ids[0]
+		ids = ids[1:]
+		return id, nil
+	}
+	defer func() { newCorrelationID = newBlobKey }()
+
+	deadlineBackoff = time.Millisecond
+	defer func() { deadlineBackoff = 250 * time.Millisecond }()
+
+	var sent string
+	c := NewConn(recordIO(&sent,
+		// Call 1 waits out a DEADLINE_SOON, gets a late reply to an
+		// earlier call, then its own.
+		"USING req\r\nINSERTED 1\r\nWATCHING 2\r\nWATCHING 1\r\nDEADLINE_SOON\r\n"+
+			envelopeResp(t, 2, "stale", HeaderCorrelationID, "c0")+"DELETED\r\n"+
+			envelopeResp(t, 3, "pong", HeaderCorrelationID, "c1")+"DELETED\r\n"+
+			// Call 2 times out before a server reserves the request.
+			"INSERTED 4\r\nTIMED_OUT\r\nDELETED\r\n"+
+			// Call 3 gets an error.
+			"INSERTED 5\r\n"+
+			envelopeResp(t, 6, "", HeaderCorrelationID, "c3", HeaderRPCError, "boom")+"DELETED\r\n",
+	))
+	cl := &RPCClient{Tube: NewTube(c, "req"), ReplyTube: "reply"}
+	body, err := cl.Call("ping", []byte("x"), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "pong" {
+		t.Fatalf("expected pong, got %q", body)
+	}
+	if _, err := cl.Call("ping", nil, 0); !errors.Is(err, ErrTimeout) {
+		t.Fatal("expected ErrTimeout, got", err)
+	}
+	if _, err := cl.Call("ping", nil, time.Second); err != RPCError("boom") {
+		t.Fatal("expected RPCError, got", err)
+	}
+	for _, s := range []string{
+		"Reply-To: reply\r\n", "Rpc-Method: ping\r\n", "Correlation-Id: c1\r\n",
+		"delete 2\r\n", "delete 3\r\n",
+		"reserve-with-timeout 0\r\ndelete 4\r\n",
+	} {
+		if !strings.Contains(sent, s) {
+			t.Errorf("sent lacks %q", s)
+		}
+	}
+}
+
+func TestRPCServer(t *testing.T) {
+	var sent string
+	c := NewConn(recordIO(&sent,
+		"WATCHING 2\r\nWATCHING 1\r\n"+
+			envelopeResp(t, 1, "hi", HeaderRPCMethod, "echo", HeaderReplyTo, "r1", HeaderCorrelationID, "a")+
+			"USING r1\r\nINSERTED 10\r\nDELETED\r\n"+
+			envelopeResp(t, 2, "", HeaderRPCMethod, "nope", HeaderReplyTo, "r1", HeaderCorrelationID, "b")+
+			"INSERTED 11\r\nDELETED\r\n"+
+			envelopeResp(t, 3, "", HeaderRPCMethod, "echo")+
+			"OK 11\r\n---\npri: 5\n\r\nBURIED\r\n"+
+			envelopeResp(t, 4, "", HeaderRPCMethod, "echo", HeaderReplyTo, "bad!")+
+			"OK 11\r\n---\npri: 6\n\r\nBURIED\r\n",
+	))
+	s := NewRPCServer(c, "rpc")
+	calls := 0
+	s.Handle("echo", func(body []byte) ([]byte, error) { calls++; return body, nil })
+	var reported []error
+	s.OnError = func(err error) { reported = append(reported, err) }
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Serve(ctx); !errors.Is(err, io.EOF) {
+		t.Fatal("expected EOF, got", err)
+	}
+	if calls != 1 {
+		t.Fatalf("handler called %d times", calls)
+	}
+	var nerr NameError
+	if len(reported) != 2 || reported[0] != ErrNoReplyTo || !errors.As(reported[1], &nerr) {
+		t.Fatal("reported", reported)
+	}
+	for _, s := range []string{
+		"Correlation-Id: a\r\n",
+		"\r\n\r\nhi\r\ndelete 1\r\n",
+		"Correlation-Id: b\r\n",
+		"Rpc-Error: unknown method \"nope\"\r\n",
+		"stats-job 3\r\nbury 3 5\r\n",
+		"stats-job 4\r\nbury 4 6\r\n",
+	} {
+		if !strings.Contains(sent, s) {
+			t.Errorf("sent lacks %q", s)
+		}
+	}
+}
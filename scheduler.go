@@ -0,0 +1,176 @@
+package beanstalk
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// DefaultLockTTR is the lease length used by a Scheduler when
+// LockTTR is zero.
+const DefaultLockTTR = 30 * time.Second
+
+// A Scheduler puts jobs into tubes on cron schedules.
+//
+// Several Schedulers may run against the same server with the same
+// LockTube; only the one currently holding the lock job enqueues
+// jobs. The lock job is an ordinary job in LockTube: holding it
+// reserved is a lease that lasts for its TTR, and the holder touches
+// it periodically to keep it. If the holder goes away, the server
+// releases the lock job when its TTR expires and another Scheduler
+// reserves it and takes over. Ticks that fall while no Scheduler
+// holds the lease are skipped.
+//
+// If the lock tube holds no job, a Scheduler puts the lock job itself. Two
+// Schedulers starting at the same moment may both do so; to rule
+// that out, put the lock job once when deploying.
+//
+// Run blocks Conn in long reserves, so a Scheduler should have a
+// connection to itself.
+type Scheduler struct {
+	Conn     *Conn
+	LockTube string
+
+	// LockTTR is the TTR of the lock job put by the Scheduler.
+	// If zero, DefaultLockTTR is used.
+	LockTTR time.Duration
+
+	entries []*schedEntry
+}
+
+type schedEntry struct {
+	sched *Schedule
+	tube  *Tube
+	body  []byte
+	pri   uint32
+	ttr   time.Duration
+	next  time.Time
+}
+
+// NewScheduler returns a new Scheduler using c and the named lock tube.
+func NewScheduler(c *Conn, lockTube string) *Scheduler {
+	return &Scheduler{Conn: c, LockTube: lockTube}
+}
+
+// Add arranges for a job with the given body, priority, and TTR to be
+// put into the named tube at each time matched by the cron expression
+// spec. See ParseSchedule for the syntax. Add must not be called
+// while Run is running.
+func (s *Scheduler) Add(spec, tube string, body []byte, pri uint32, ttr time.Duration) error {
+	sched, err := ParseSchedule(spec)
+	if err != nil {
+		return err
+	}
+	if err = checkName(tube); err != nil {
+		return err
+	}
+	s.entries = append(s.entries, &schedEntry{
+		sched: sched,
+		tube:  NewTube(s.Conn, tube),
+		body:  body,
+		pri:   pri,
+		ttr:   ttr,
+	})
+	return nil
+}
+
+func (s *Scheduler) lockTTR() time.Duration {
+	if s.LockTTR > 0 {
+		return s.LockTTR
+	}
+	return DefaultLockTTR
+}
+
+// Run competes for the lock job and, while holding it, puts jobs on
+// schedule. It returns when ctx is done, releasing the lock job if
+// it holds it, or when an error occurs.
+func (s *Scheduler) Run(ctx context.Context) error {
+	lock := NewTubeSet(s.Conn, s.LockTube)
+	for ctx.Err() == nil {
+		id, _, err := lock.Reserve(s.lockTTR() / 2)
+		if errors.Is(err, ErrTimeout) {
+			err = s.ensureLock()
+		} else if err == nil {
+			err = s.lead(ctx, id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureLock puts the lock job if the lock tube is empty.
+func (s *Scheduler) ensureLock() error {
+	t := NewTube(s.Conn, s.LockTube)
+	stats, err := t.Stats()
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	n := 0
+	for _, k := range []string{"ready", "reserved", "delayed", "buried"} {
+		m, _ := strconv.Atoi(stats["current-jobs-"+k])
+		n += m
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err = t.Put([]byte("lock"), 0, 0, s.lockTTR())
+	return err
+}
+
+// lead puts jobs on schedule while holding the lock job id.
+// It returns nil if the lease is lost.
+func (s *Scheduler) lead(ctx context.Context, id uint64) error {
+	t := now()
+	for _, e := range s.entries {
+		e.next = e.sched.Next(t)
+	}
+	renew := s.lockTTR() / 3
+	touched := t
+	for {
+		wake := touched.Add(renew)
+		for _, e := range s.entries {
+			if !e.next.IsZero() && e.next.Before(wake) {
+				wake = e.next
+			}
+		}
+		timer := time.NewTimer(wake.Sub(now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return s.Conn.Release(id, 0, 0)
+		case <-timer.C:
+		}
+		t = now()
+		if !t.Before(touched.Add(renew)) {
+			err := s.Conn.Touch(id)
+			if errors.Is(err, ErrNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			touched = t
+		}
+		if err := s.fire(t); err != nil {
+			return err
+		}
+	}
+}
+
+// fire puts the jobs for entries due at or before t and advances
+// their next times past t.
+func (s *Scheduler) fire(t time.Time) error {
+	for _, e := range s.entries {
+		if e.next.IsZero() || e.next.After(t) {
+			continue
+		}
+		e.next = e.sched.Next(t)
+		if _, err := e.tube.Put(e.body, e.pri, 0, e.ttr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package beanstalk
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedulerAddBad(t *testing.T) {
+	s := NewScheduler(NewConn(mock("", "")), "lock")
+	if err := s.Add("* * *", "foo", nil, 0, 0); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := s.Add("* * * * *", "*", nil, 0, 0); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestSchedulerFire(t *testing.T) {
+	c := NewConn(mock("use foo\r\nput 1 0 60 1\r\nx\r\n", "USING foo\r\nINSERTED 1\r\n"))
+	s := NewScheduler(c, "lock")
+	if err := s.Add("*/5 * * * *", "foo", []byte("x"), 1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.entries[0].next = s.entries[0].sched.Next(t0)
+	if err := s.fire(t0.Add(4 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.fire(t0.Add(5 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if exp := t0.Add(10 * time.Minute); !s.entries[0].next.Equal(exp) {
+		t.Fatalf("expected next %v, got %v", exp, s.entries[0].next)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchedulerEnsureLock(t *testing.T) {
+	c := NewConn(mock(
+		"stats-tube lock\r\nuse lock\r\nput 0 0 30 4\r\nlock\r\n",
+		"NOT_FOUND\r\nUSING lock\r\nINSERTED 1\r\n",
+	))
+	if err := NewScheduler(c, "lock").ensureLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchedulerEnsureLockExists(t *testing.T) {
+	c := NewConn(mock("stats-tube lock\r\n", "OK 29\r\n---\ncurrent-jobs-reserved: 1\n\r\n"))
+	if err := NewScheduler(c, "lock").ensureLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,155 @@
+package beanstalk
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// ErrTampered indicates that a sealed job body could not be
+// authenticated, either because it was modified or because it was
+// sealed with a key that is not available.
+var ErrTampered = errors.New("job body failed authentication")
+
+const (
+	sealEncrypt = 'e'
+	sealSign    = 's'
+)
+
+var sealMagic = []byte("\x00BSS")
+
+// A Keyring holds secret keys indexed by key id. Bodies are sealed
+// with the Current key and opened with whichever key they name, so
+// keys can be rotated by adding a new key, making it Current, and
+// removing the old key once no jobs sealed with it remain.
+type Keyring struct {
+	Current string
+	Keys    map[string][]byte
+}
+
+// SealCodec wraps another Codec, sealing encoded bodies with keys from
+// Keyring. By default bodies are encrypted and authenticated with
+// AES-GCM; keys must be 16, 24, or 32 bytes long. If SignOnly is set,
+// bodies are left readable and authenticated with HMAC-SHA256.
+//
+// Unmarshal returns an error wrapping ErrTampered for any body that
+// is not sealed or fails authentication.
+type SealCodec struct {
+	Codec    Codec // if nil, RawCodec is used
+	Keyring  *Keyring
+	SignOnly bool
+}
+
+func (c SealCodec) codec() Codec {
+	if c.Codec == nil {
+		return RawCodec{}
+	}
+	return c.Codec
+}
+
+// Marshal encodes v with the wrapped Codec and seals the result with
+// the current key.
+func (c SealCodec) Marshal(v interface{}) ([]byte, error) {
+	b, err := c.codec().Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	kid := c.Keyring.Current
+	key, ok := c.Keyring.Keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("seal: no key for current key id %q", kid)
+	}
+	if len(kid) > 255 {
+		return nil, fmt.Errorf("seal: key id too long: %q", kid)
+	}
+	mode := byte(sealEncrypt)
+	if c.SignOnly {
+		mode = sealSign
+	}
+	head := append(sealMagic[:len(sealMagic):len(sealMagic)], mode, byte(len(kid)))
+	head = append(head, kid...)
+	if c.SignOnly {
+		m := hmac.New(sha256.New, key)
+		m.Write(head)
+		m.Write(b)
+		return append(m.Sum(head), b...), nil
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(append(head, nonce...), nonce, b, head), nil
+}
+
+// Unmarshal authenticates and, if necessary, decrypts data, then
+// decodes it into v with the wrapped Codec.
+func (c SealCodec) Unmarshal(data []byte, v interface{}) error {
+	b, err := c.open(data)
+	if err != nil {
+		return err
+	}
+	return c.codec().Unmarshal(b, v)
+}
+
+func (c SealCodec) open(data []byte) ([]byte, error) {
+	n := len(sealMagic)
+	if !bytes.HasPrefix(data, sealMagic) || len(data) < n+2 {
+		return nil, fmt.Errorf("%w: body is not sealed", ErrTampered)
+	}
+	mode, kidLen := data[n], int(data[n+1])
+	if len(data) < n+2+kidLen {
+		return nil, ErrTampered
+	}
+	head, rest := data[:n+2+kidLen], data[n+2+kidLen:]
+	kid := string(head[n+2:])
+	key, ok := c.Keyring.Keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key id %q", ErrTampered, kid)
+	}
+	switch mode {
+	case sealSign:
+		if len(rest) < sha256.Size {
+			return nil, ErrTampered
+		}
+		sum, b := rest[:sha256.Size], rest[sha256.Size:]
+		m := hmac.New(sha256.New, key)
+		m.Write(head)
+		m.Write(b)
+		if !hmac.Equal(sum, m.Sum(nil)) {
+			return nil, ErrTampered
+		}
+		return b, nil
+	case sealEncrypt:
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) < aead.NonceSize() {
+			return nil, ErrTampered
+		}
+		nonce, sealed := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+		b, err := aead.Open(nil, nonce, sealed, head)
+		if err != nil {
+			return nil, ErrTampered
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("%w: unknown seal mode %q", ErrTampered, mode)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,81 @@
+package beanstalk
+
+import (
+	"errors"
+	"testing"
+)
+
+var testKeyring = &Keyring{
+	Current: "k2",
+	Keys: map[string][]byte{
+		"k1": []byte("0123456789abcdef"),
+		"k2": []byte("fedcba9876543210"),
+	},
+}
+
+func TestSealCodec(t *testing.T) {
+	for _, signOnly := range []bool{false, true} {
+		codec := SealCodec{Keyring: testKeyring, SignOnly: signOnly}
+		b, err := codec.Marshal("secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out string
+		if err = codec.Unmarshal(b, &out); err != nil {
+			t.Fatal(err)
+		}
+		if out != "secret" {
+			t.Fatalf("expected %#v, got %#v", "secret", out)
+		}
+	}
+}
+
+func TestSealCodecRotation(t *testing.T) {
+	old := SealCodec{Keyring: &Keyring{"k1", testKeyring.Keys}}
+	b, err := old.Marshal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out string
+	if err = (SealCodec{Keyring: testKeyring}).Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out != "secret" {
+		t.Fatalf("expected %#v, got %#v", "secret", out)
+	}
+}
+
+func TestSealCodecTampered(t *testing.T) {
+	for _, signOnly := range []bool{false, true} {
+		codec := SealCodec{Keyring: testKeyring, SignOnly: signOnly}
+		b, err := codec.Marshal("secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b[len(b)-1] ^= 1
+		var out string
+		if err = codec.Unmarshal(b, &out); !errors.Is(err, ErrTampered) {
+			t.Fatal("expected ErrTampered, got", err)
+		}
+	}
+}
+
+func TestSealCodecUnknownKey(t *testing.T) {
+	b, err := SealCodec{Keyring: testKeyring}.Marshal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out string
+	err = SealCodec{Keyring: &Keyring{Keys: map[string][]byte{}}}.Unmarshal(b, &out)
+	if !errors.Is(err, ErrTampered) {
+		t.Fatal("expected ErrTampered, got", err)
+	}
+}
+
+func TestSealCodecUnsealed(t *testing.T) {
+	var out string
+	err := SealCodec{Keyring: testKeyring}.Unmarshal([]byte("plain"), &out)
+	if !errors.Is(err, ErrTampered) {
+		t.Fatal("expected ErrTampered, got", err)
+	}
+}
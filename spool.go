@@ -0,0 +1,524 @@
+package beanstalk
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Spool defaults.
+const (
+	DefaultSpoolMaxBytes    = 1 << 30
+	DefaultSpoolSegmentSize = 16 << 20
+)
+
+// Spool errors.
+var (
+	ErrSpoolFull    = errors.New("spool full")
+	ErrSpoolCorrupt = errors.New("spool corrupt")
+)
+
+// SpoolSync says when a Spool calls fsync.
+type SpoolSync int
+
+const (
+	SpoolSyncNone    SpoolSync = iota // leave it to the operating system
+	SpoolSyncSegment                  // when a segment is full
+	SpoolSyncAlways                   // after every job spooled or replayed
+)
+
+const (
+	spoolExt        = ".seg"
+	spoolCursorName = "cursor"
+	spoolHeaderLen  = 8  // length, CRC
+	spoolFixedLen   = 29 // tube length, pri, delay, ttr, time
+)
+
+// Spool is a producer that puts jobs through a connection from Dial,
+// or, while the server is unreachable or refuses jobs with ErrOOM or
+// ErrDraining, appends them to segment files in a local directory.
+// Spooled jobs are replayed in order once the server accepts jobs
+// again, before any newer job is put. A job's delay counts from when it
+// was first given to Put.
+//
+// Replay happens at the start of each Put and Flush, and periodically
+// during Run. A job can be put twice if the process stops between
+// putting it and recording that it was put.
+//
+// The exported fields may be changed until the first call to Put.
+type Spool struct {
+	Dial func() (*Conn, error)
+
+	// MaxBytes bounds the total size of the segment files. If zero,
+	// DefaultSpoolMaxBytes is used.
+	MaxBytes int64
+
+	// SegmentSize is the size at which a new segment file is started.
+	// If zero, DefaultSpoolSegmentSize is used.
+	SegmentSize int64
+
+	Sync SpoolSync
+
+	// OnError, if non-nil, is called with the error for each spooled
+	// job that the server rejected for a reason other than being
+	// unavailable, such as ErrJobTooBig, and with an error wrapping
+	// ErrSpoolCorrupt for each damaged record found while replaying.
+	// Such jobs are discarded.
+	OnError func(error)
+
+	dir    string
+	mu     sync.Mutex
+	conn   *Conn
+	segs   []int64 // segment numbers, oldest first
+	size   int64   // total size of segs
+	w      *os.File
+	wsize  int64
+	cursor *os.File
+	rseg   int64 // segment and offset of the next job to replay
+	roff   int64
+}
+
+// OpenSpool opens the spool in dir, creating dir if necessary, and
+// returns a Spool that connects with dial. If the spool's last job was
+// only partly written, it is removed.
+func OpenSpool(dir string, dial func() (*Conn, error)) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	s := &Spool{Dial: dial, dir: dir}
+	names, err := filepath.Glob(filepath.Join(dir, "*"+spoolExt))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		n, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(name), spoolExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		fi, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		s.segs = append(s.segs, n)
+		s.size += fi.Size()
+	}
+	sort.Slice(s.segs, func(i, j int) bool { return s.segs[i] < s.segs[j] })
+
+	s.cursor, err = os.OpenFile(filepath.Join(dir, spoolCursorName), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	var b [16]byte
+	if _, err := io.ReadFull(s.cursor, b[:]); err == nil {
+		s.rseg = int64(binary.BigEndian.Uint64(b[:8]))
+		s.roff = int64(binary.BigEndian.Uint64(b[8:]))
+	}
+	for len(s.segs) > 0 && s.segs[0] < s.rseg {
+		s.removeFirst() // replayed before a crash
+	}
+	if len(s.segs) > 0 && s.segs[0] > s.rseg {
+		s.rseg, s.roff = s.segs[0], 0
+	}
+	if len(s.segs) > 0 {
+		if err := s.repairLast(); err != nil {
+			s.cursor.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Spool) segName(n int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%016d%s", n, spoolExt))
+}
+
+// repairLast opens the last segment for appending, first truncating
+// any partly written job at its end.
+func (s *Spool) repairLast() error {
+	n := s.segs[len(s.segs)-1]
+	f, err := os.OpenFile(s.segName(n), os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	var off int64
+	for {
+		_, size, err := readSpooled(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fi, serr := f.Stat()
+			if serr != nil {
+				f.Close()
+				return serr
+			}
+			if err := f.Truncate(off); err != nil {
+				f.Close()
+				return err
+			}
+			s.size -= fi.Size() - off
+			break
+		}
+		off += size
+	}
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	s.w, s.wsize = f, off
+	return nil
+}
+
+// Put puts a job into the named tube. If the server is unavailable,
+// the job is spooled and Put returns id 0 and a nil error. It returns
+// ErrSpoolFull if the job could not be put or spooled.
+func (s *Spool) Put(tube string, body []byte, pri uint32, delay, ttr time.Duration) (id uint64, err error) {
+	if err := checkName(tube); err != nil {
+		return 0, err
+	}
+	j := spooledJob{tube, body, pri, delay, ttr, now()}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.segs) > 0 {
+		if err := s.flush(); err != nil && !unavailable(err) {
+			return 0, err
+		}
+	}
+	if len(s.segs) == 0 {
+		id, err = s.put(j)
+		if !unavailable(err) {
+			return id, err
+		}
+	}
+	return 0, s.append(j)
+}
+
+// Flush replays spooled jobs until the spool is empty or the server is
+// unavailable, in which case it returns the error.
+func (s *Spool) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+// Run calls Flush every interval until ctx is done. Errors from an
+// unavailable server are ignored; it returns any other error.
+func (s *Spool) Run(ctx context.Context, interval time.Duration) error {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C:
+		}
+		if err := s.Flush(); err != nil && !unavailable(err) {
+			return err
+		}
+	}
+}
+
+// Spooled returns the total size of the segment files, in bytes.
+func (s *Spool) Spooled() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Close closes the spool's files and connection. Spooled jobs stay on
+// disk for the next OpenSpool.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	if s.w != nil {
+		if s.Sync != SpoolSyncNone {
+			err = s.w.Sync()
+		}
+		if cerr := s.w.Close(); err == nil {
+			err = cerr
+		}
+		s.w = nil
+	}
+	if cerr := s.cursor.Close(); err == nil {
+		err = cerr
+	}
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// dialError is an error from Spool.Dial.
+type dialError struct {
+	err error
+}
+
+func (e dialError) Error() string { return "dial: " + e.err.Error() }
+func (e dialError) Unwrap() error { return e.err }
+
+// unavailable reports whether err means the job should be spooled.
+func unavailable(err error) bool {
+	var nerr net.Error
+	var derr dialError
+	return errors.Is(err, ErrOOM) || errors.Is(err, ErrDraining) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) || errors.As(err, &nerr) ||
+		errors.As(err, &derr)
+}
+
+func (s *Spool) put(j spooledJob) (uint64, error) {
+	if s.conn == nil {
+		c, err := s.Dial()
+		if err != nil {
+			return 0, dialError{err}
+		}
+		s.conn = c
+	}
+	delay := j.delay - now().Sub(j.at)
+	if delay < 0 {
+		delay = 0
+	}
+	id, err := NewTube(s.conn, j.tube).Put(j.body, j.pri, delay, j.ttr)
+	if unavailable(err) && !errors.Is(err, ErrOOM) && !errors.Is(err, ErrDraining) {
+		s.conn.Close()
+		s.conn = nil
+	}
+	return id, err
+}
+
+func (s *Spool) append(j spooledJob) error {
+	b := j.marshal()
+	if s.size+int64(len(b)) > s.maxBytes() {
+		return ErrSpoolFull
+	}
+	if s.w == nil || s.wsize+int64(len(b)) > s.segmentSize() && s.wsize > 0 {
+		if err := s.roll(); err != nil {
+			return err
+		}
+	}
+	n, err := s.w.Write(b)
+	s.wsize += int64(n)
+	s.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if s.Sync == SpoolSyncAlways {
+		return s.w.Sync()
+	}
+	return nil
+}
+
+// roll starts a new segment.
+func (s *Spool) roll() error {
+	n := s.rseg
+	if len(s.segs) > 0 {
+		n = s.segs[len(s.segs)-1] + 1
+	}
+	if s.w != nil {
+		if s.Sync != SpoolSyncNone {
+			if err := s.w.Sync(); err != nil {
+				return err
+			}
+		}
+		if err := s.w.Close(); err != nil {
+			return err
+		}
+		s.w = nil
+	}
+	f, err := os.OpenFile(s.segName(n), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if len(s.segs) == 0 {
+		s.rseg, s.roff = n, 0
+		if err := s.saveCursor(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	s.segs = append(s.segs, n)
+	s.w, s.wsize = f, 0
+	return nil
+}
+
+func (s *Spool) flush() error {
+	for len(s.segs) > 0 {
+		if err := s.flushSegment(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushSegment replays the jobs in the oldest segment and removes it.
+func (s *Spool) flushSegment() error {
+	f, err := os.Open(s.segName(s.segs[0]))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(s.roff, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	for {
+		j, size, err := readSpooled(r)
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, ErrSpoolCorrupt) {
+			if s.OnError != nil {
+				s.OnError(fmt.Errorf("%w: %s at offset %d", err, f.Name(), s.roff))
+			}
+			if size == 0 {
+				// the rest of the segment can't be framed
+				break
+			}
+			s.roff += size
+			if err := s.saveCursor(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := s.put(j); unavailable(err) {
+			return err
+		} else if err != nil && s.OnError != nil {
+			s.OnError(err)
+		}
+		s.roff += size
+		if err := s.saveCursor(); err != nil {
+			return err
+		}
+	}
+	if len(s.segs) == 1 && s.w != nil {
+		if err := s.w.Close(); err != nil {
+			return err
+		}
+		s.w = nil
+	}
+	s.removeFirst()
+	if len(s.segs) > 0 {
+		s.rseg, s.roff = s.segs[0], 0
+		return s.saveCursor()
+	}
+	s.rseg++
+	s.roff = 0
+	return s.saveCursor()
+}
+
+func (s *Spool) removeFirst() {
+	name := s.segName(s.segs[0])
+	if fi, err := os.Stat(name); err == nil {
+		s.size -= fi.Size()
+	}
+	os.Remove(name)
+	s.segs = s.segs[1:]
+}
+
+func (s *Spool) saveCursor() error {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(s.rseg))
+	binary.BigEndian.PutUint64(b[8:], uint64(s.roff))
+	if _, err := s.cursor.WriteAt(b[:], 0); err != nil {
+		return err
+	}
+	if s.Sync == SpoolSyncAlways {
+		return s.cursor.Sync()
+	}
+	return nil
+}
+
+func (s *Spool) maxBytes() int64 {
+	if s.MaxBytes == 0 {
+		return DefaultSpoolMaxBytes
+	}
+	return s.MaxBytes
+}
+
+func (s *Spool) segmentSize() int64 {
+	if s.SegmentSize == 0 {
+		return DefaultSpoolSegmentSize
+	}
+	return s.SegmentSize
+}
+
+type spooledJob struct {
+	tube       string
+	body       []byte
+	pri        uint32
+	delay, ttr time.Duration
+	at         time.Time
+}
+
+// marshal encodes j as a length, a CRC-32 of the rest, the tube name
+// preceded by its length, pri, delay, ttr, the time of the first put,
+// and the body.
+func (j spooledJob) marshal() []byte {
+	n := spoolFixedLen + len(j.tube) + len(j.body)
+	b := make([]byte, spoolHeaderLen+n)
+	binary.BigEndian.PutUint32(b, uint32(n))
+	p := b[spoolHeaderLen:]
+	p[0] = byte(len(j.tube))
+	p = p[1+copy(p[1:], j.tube):]
+	binary.BigEndian.PutUint32(p, j.pri)
+	binary.BigEndian.PutUint64(p[4:], uint64(j.delay))
+	binary.BigEndian.PutUint64(p[12:], uint64(j.ttr))
+	binary.BigEndian.PutUint64(p[20:], uint64(j.at.UnixNano()))
+	copy(p[28:], j.body)
+	binary.BigEndian.PutUint32(b[4:], crc32.ChecksumIEEE(b[spoolHeaderLen:]))
+	return b
+}
+
+// readSpooled reads a job and returns it with its encoded size. It
+// returns io.EOF only at the end of r, and ErrSpoolCorrupt if the job
+// is truncated or damaged. A damaged job whose length was intact is
+// returned with its size, so the next one can still be read.
+func readSpooled(r *bufio.Reader) (j spooledJob, size int64, err error) {
+	var h [spoolHeaderLen]byte
+	if _, err := io.ReadFull(r, h[:]); err == io.EOF {
+		return j, 0, io.EOF
+	} else if err != nil {
+		return j, 0, ErrSpoolCorrupt
+	}
+	n := binary.BigEndian.Uint32(h[:4])
+	if n < spoolFixedLen || n > 1<<31 {
+		return j, 0, ErrSpoolCorrupt
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return j, 0, ErrSpoolCorrupt
+	}
+	size = int64(spoolHeaderLen + n)
+	if crc32.ChecksumIEEE(b) != binary.BigEndian.Uint32(h[4:]) {
+		return j, size, ErrSpoolCorrupt
+	}
+	tl := int(b[0])
+	if int(n) < spoolFixedLen+tl {
+		return j, size, ErrSpoolCorrupt
+	}
+	j.tube, b = string(b[1:1+tl]), b[1+tl:]
+	j.pri = binary.BigEndian.Uint32(b)
+	j.delay = time.Duration(binary.BigEndian.Uint64(b[4:]))
+	j.ttr = time.Duration(binary.BigEndian.Uint64(b[12:]))
+	j.at = time.Unix(0, int64(binary.BigEndian.Uint64(b[20:])))
+	j.body = b[28:]
+	return j, size, nil
+}
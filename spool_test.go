@@ -0,0 +1,259 @@
+package beanstalk
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// dialer returns a Spool dial function whose successive calls return
+// a mock connection for each pair of recv and send, or fail for an
+// empty pair or once the pairs run out. Each mock connection is
+// checked when the test ends.
+func dialer(t *testing.T, conns ...[2]string) func() (*Conn, error) {
+	var used []*Conn
+	t.Cleanup(func() {
+		for _, c := range used {
+			if err := c.Close(); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	return func() (*Conn, error) {
+		if len(conns) == 0 {
+			return nil, errors.New("refused")
+		}
+		cs := conns[0]
+		conns = conns[1:]
+		if cs[0] == "" && cs[1] == "" {
+			return nil, errors.New("refused")
+		}
+		c := NewConn(mock(cs[0], cs[1]))
+		used = append(used, c)
+		return c, nil
+	}
+}
+
+func spoolFiles(t *testing.T, dir string) int {
+	names, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(names)
+}
+
+func TestSpoolDirect(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenSpool(dir, dialer(t, [2]string{
+		"use foo\r\nput 1 0 10 1\r\na\r\n",
+		"USING foo\r\nINSERTED 7\r\n",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := s.Put("foo", []byte("a"), 1, 0, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 7 {
+		t.Fatal("expected 7, got", id)
+	}
+	if n := spoolFiles(t, dir); n != 0 {
+		t.Fatalf("expected no segments, got %d", n)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpoolReplay(t *testing.T) {
+	t0 := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	setNow(t, t0)
+	dir := t.TempDir()
+	s, err := OpenSpool(dir, dialer(t,
+		[2]string{}, // unreachable
+		[2]string{
+			"use foo\r\nput 1 30 10 1\r\na\r\n" +
+				"put 1 20 10 1\r\na\r\nput 2 0 10 1\r\nb\r\nuse bar\r\nput 3 0 10 1\r\nc\r\n",
+			"USING foo\r\nDRAINING\r\n" +
+				"INSERTED 1\r\nINSERTED 2\r\nUSING bar\r\nINSERTED 3\r\n",
+		},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SegmentSize = 1 // a segment per job
+	for _, body := range []string{"a", "b"} {
+		delay := time.Duration(0)
+		if body == "a" {
+			delay = 30 * time.Second
+		}
+		id, err := s.Put("foo", []byte(body), uint32(body[0]-'a'+1), delay, 10*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != 0 {
+			t.Fatal("expected job to be spooled, got id", id)
+		}
+		// The second put tries to replay the first, but the server
+		// is draining.
+	}
+	if n := spoolFiles(t, dir); n != 2 {
+		t.Fatalf("expected 2 segments, got %d", n)
+	}
+	setNow(t, t0.Add(10*time.Second))
+	id, err := s.Put("bar", []byte("c"), 3, 0, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 3 {
+		t.Fatal("expected 3, got", id)
+	}
+	if n := spoolFiles(t, dir); n != 0 || s.Spooled() != 0 {
+		t.Fatalf("expected empty spool, got %d segments, %d bytes", n, s.Spooled())
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpoolReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenSpool(dir, dialer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Sync = SpoolSyncAlways
+	for _, body := range []string{"a", "b"} {
+		if _, err := s.Put("default", []byte(body), 0, 0, time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash part way through writing a third job.
+	seg := filepath.Join(dir, "0000000000000000.seg")
+	f, err := os.OpenFile(seg, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{0, 0, 0, 40, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	s, err = OpenSpool(dir, dialer(t, [2]string{
+		"put 0 0 1 1\r\na\r\nput 0 0 1 1\r\nb\r\n",
+		"INSERTED 1\r\nINSERTED 2\r\n",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpoolFull(t *testing.T) {
+	s, err := OpenSpool(t.TempDir(), dialer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.MaxBytes = 50
+	if _, err := s.Put("default", []byte("a"), 0, 0, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put("default", []byte("b"), 0, 0, time.Second); err != ErrSpoolFull {
+		t.Fatal("expected ErrSpoolFull, got", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpoolRejected(t *testing.T) {
+	s, err := OpenSpool(t.TempDir(), dialer(t,
+		[2]string{},
+		[2]string{"put 0 0 1 1\r\na\r\n", "JOB_TOO_BIG\r\n"},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rejected error
+	s.OnError = func(err error) { rejected = err }
+	if _, err := s.Put("default", []byte("a"), 0, 0, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(rejected, ErrJobTooBig) {
+		t.Fatal("expected ErrJobTooBig, got", rejected)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpoolCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenSpool(dir, dialer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SegmentSize = 1 // a segment per job
+	for _, body := range []string{"a", "b", "c"} {
+		if _, err := s.Put("default", []byte(body), 0, 0, time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Damage the body of the first job, and the length of the second.
+	for i, off := range []int64{spoolHeaderLen + spoolFixedLen + 7, 0} {
+		f, err := os.OpenFile(s.segName(int64(i)), os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteAt([]byte{0xff}, off); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+
+	s, err = OpenSpool(dir, dialer(t, [2]string{
+		"put 0 0 1 1\r\nc\r\n",
+		"INSERTED 3\r\n",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var corrupt int
+	s.OnError = func(err error) {
+		if !errors.Is(err, ErrSpoolCorrupt) {
+			t.Error("expected ErrSpoolCorrupt, got", err)
+		}
+		corrupt++
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if corrupt != 2 {
+		t.Fatal("expected 2 corrupt records, got", corrupt)
+	}
+	if n := spoolFiles(t, dir); n != 0 {
+		t.Fatalf("expected no segments, got %d", n)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
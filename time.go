@@ -1,12 +1,38 @@
 package beanstalk
 
 import (
+	"errors"
 	"strconv"
 	"time"
 )
 
+// MaxDelay is the longest delay the protocol can express.
+const MaxDelay = (1<<32 - 1) * time.Second
+
+// ErrDelayTooLong indicates that a time is too far in the future to
+// be expressed as a delay in the protocol.
+var ErrDelayTooLong = errors.New("delay exceeds protocol limit")
+
+// now is the clock used to compute delays; tests replace it.
+var now = time.Now
+
 type dur time.Duration
 
 func (d dur) String() string {
 	return strconv.FormatInt(int64(time.Duration(d)/time.Second), 10)
 }
+
+// delayUntil returns the delay from now until at, rounded up to a
+// whole second so the job is never ready early. Times in the past
+// give a zero delay.
+func delayUntil(at time.Time) (time.Duration, error) {
+	d := at.Sub(now())
+	if d <= 0 {
+		return 0, nil
+	}
+	d = (d + time.Second - 1) / time.Second * time.Second
+	if d > MaxDelay {
+		return 0, ErrDelayTooLong
+	}
+	return d, nil
+}
@@ -3,6 +3,7 @@ package beanstalk
 import (
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestFormatDuration(t *testing.T) {
@@ -12,3 +13,33 @@ func TestFormatDuration(t *testing.T) {
 		t.Fatal("got", s, "expected 100")
 	}
 }
+
+func setNow(t *testing.T, tm time.Time) {
+	now = func() time.Time { return tm }
+	t.Cleanup(func() { now = time.Now })
+}
+
+func TestDelayUntil(t *testing.T) {
+	t0 := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	setNow(t, t0)
+	for _, tt := range []struct {
+		at  time.Time
+		exp time.Duration
+	}{
+		{t0.Add(-time.Hour), 0},
+		{t0, 0},
+		{t0.Add(1500 * time.Millisecond), 2 * time.Second},
+		{t0.Add(MaxDelay), MaxDelay},
+	} {
+		d, err := delayUntil(tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d != tt.exp {
+			t.Fatalf("%v: expected %v, got %v", tt.at, tt.exp, d)
+		}
+	}
+	if _, err := delayUntil(t0.Add(MaxDelay + time.Second)); err != ErrDelayTooLong {
+		t.Fatal("expected ErrDelayTooLong, got", err)
+	}
+}
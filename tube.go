@@ -32,6 +32,17 @@ func (t *Tube) Put(body []byte, pri uint32, delay, ttr time.Duration) (id uint64
 	return id, nil
 }
 
+// PutAt puts a job into tube t as in Put, delaying it until time at.
+// It returns ErrDelayTooLong if at is beyond MaxDelay from now; see
+// Holder for scheduling further ahead.
+func (t *Tube) PutAt(body []byte, pri uint32, at time.Time, ttr time.Duration) (id uint64, err error) {
+	delay, err := delayUntil(at)
+	if err != nil {
+		return 0, err
+	}
+	return t.Put(body, pri, delay, ttr)
+}
+
 // PeekReady gets a copy of the job at the front of t's ready queue.
 func (t *Tube) PeekReady() (id uint64, body []byte, err error) {
 	r, err := t.Conn.cmd(t, nil, nil, "peek-ready")
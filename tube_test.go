@@ -115,3 +115,20 @@ func TestTubePause(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestTubePutAt(t *testing.T) {
+	t0 := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	setNow(t, t0)
+	c := NewConn(mock("put 0 61 0 3\r\nfoo\r\n", "INSERTED 1\r\n"))
+
+	id, err := c.PutAt([]byte("foo"), 0, t0.Add(time.Minute+time.Millisecond), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 {
+		t.Fatal("expected 1, got", id)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,105 @@
+package beanstalk
+
+import (
+	"errors"
+	"time"
+)
+
+// TypedTube is a Tube whose job bodies are values of type T,
+// encoded with Codec.
+type TypedTube[T any] struct {
+	Tube  *Tube
+	Codec Codec
+}
+
+// NewTypedTube returns a new TypedTube representing the given name.
+func NewTypedTube[T any](c *Conn, name string, codec Codec) *TypedTube[T] {
+	return &TypedTube[T]{NewTube(c, name), codec}
+}
+
+// Put encodes v and puts it into t. See Tube.Put for the meaning of
+// the other arguments.
+func (t *TypedTube[T]) Put(v T, pri uint32, delay, ttr time.Duration) (id uint64, err error) {
+	body, err := t.Codec.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	return t.Tube.Put(body, pri, delay, ttr)
+}
+
+// PeekReady gets and decodes a copy of the job at the front of t's
+// ready queue. If the body cannot be decoded, the id of the job is
+// returned along with the error.
+func (t *TypedTube[T]) PeekReady() (id uint64, v T, err error) {
+	return t.decode(t.Tube.PeekReady())
+}
+
+// PeekDelayed gets and decodes a copy of the delayed job that is next
+// to be put in t's ready queue.
+func (t *TypedTube[T]) PeekDelayed() (id uint64, v T, err error) {
+	return t.decode(t.Tube.PeekDelayed())
+}
+
+// PeekBuried gets and decodes a copy of the job in the holding area
+// that would be kicked next by Kick.
+func (t *TypedTube[T]) PeekBuried() (id uint64, v T, err error) {
+	return t.decode(t.Tube.PeekBuried())
+}
+
+// Peek gets and decodes a copy of the specified job from the server.
+func (t *TypedTube[T]) Peek(id uint64) (v T, err error) {
+	body, err := t.Tube.Conn.Peek(id)
+	if err != nil {
+		return v, err
+	}
+	err = t.Codec.Unmarshal(body, &v)
+	return v, err
+}
+
+func (t *TypedTube[T]) decode(id uint64, body []byte, err error) (uint64, T, error) {
+	var v T
+	if err != nil {
+		return 0, v, err
+	}
+	err = t.Codec.Unmarshal(body, &v)
+	return id, v, err
+}
+
+// TypedTubeSet is a TubeSet whose job bodies are values of type T,
+// decoded with Codec.
+type TypedTubeSet[T any] struct {
+	TubeSet *TubeSet
+	Codec   Codec
+
+	// If BuryTampered is set, Reserve buries jobs whose bodies fail
+	// with ErrTampered, keeping their current priority.
+	BuryTampered bool
+}
+
+// NewTypedTubeSet returns a new TypedTubeSet representing the given names.
+func NewTypedTubeSet[T any](c *Conn, codec Codec, name ...string) *TypedTubeSet[T] {
+	return &TypedTubeSet[T]{TubeSet: NewTubeSet(c, name...), Codec: codec}
+}
+
+// Reserve reserves a job from one of the tubes in t and decodes its
+// body. See TubeSet.Reserve for details.
+//
+// If the body cannot be decoded, Reserve returns the id of the job
+// along with the error, so the caller can bury or delete it.
+func (t *TypedTubeSet[T]) Reserve(timeout time.Duration) (id uint64, v T, err error) {
+	id, body, err := t.TubeSet.Reserve(timeout)
+	if err != nil {
+		return 0, v, err
+	}
+	err = t.Codec.Unmarshal(body, &v)
+	if t.BuryTampered && errors.Is(err, ErrTampered) {
+		pri, perr := t.TubeSet.Conn.jobPri(id)
+		if perr != nil {
+			return id, v, perr
+		}
+		if berr := t.TubeSet.Conn.Bury(id, pri); berr != nil {
+			return id, v, berr
+		}
+	}
+	return id, v, err
+}
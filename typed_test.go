@@ -0,0 +1,116 @@
+package beanstalk
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTypedTubePut(t *testing.T) {
+	c := NewConn(mock(
+		"use foo\r\nput 0 0 0 15\r\n{\"A\":\"x\",\"B\":1}\r\n",
+		"USING foo\r\nINSERTED 1\r\n",
+	))
+	tube := NewTypedTube[codecTestValue](c, "foo", JSONCodec{})
+	id, err := tube.Put(codecTestValue{"x", 1}, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 {
+		t.Fatal("expected 1, got", id)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTypedTubeSetReserve(t *testing.T) {
+	c := NewConn(mock(
+		"reserve-with-timeout 1\r\n",
+		"RESERVED 1 15\r\n{\"A\":\"x\",\"B\":1}\r\n",
+	))
+	ts := NewTypedTubeSet[codecTestValue](c, JSONCodec{}, "default")
+	id, v, err := ts.Reserve(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 {
+		t.Fatal("expected 1, got", id)
+	}
+	if v != (codecTestValue{"x", 1}) {
+		t.Fatalf("expected %#v, got %#v", codecTestValue{"x", 1}, v)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTypedTubeSetReserveBadBody(t *testing.T) {
+	c := NewConn(mock("reserve-with-timeout 1\r\n", "RESERVED 1 1\r\nx\r\n"))
+	ts := NewTypedTubeSet[codecTestValue](c, JSONCodec{}, "default")
+	id, _, err := ts.Reserve(time.Second)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if id != 1 {
+		t.Fatal("expected 1, got", id)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTypedTubePeekReady(t *testing.T) {
+	c := NewConn(mock("peek-ready\r\n", "FOUND 1 1\r\nx\r\n"))
+	tube := NewTypedTube[string](c, "default", GzipCodec{})
+	id, v, err := tube.PeekReady()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 || v != "x" {
+		t.Fatalf("expected 1 %#v, got %d %#v", "x", id, v)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTypedTubePeekGzip(t *testing.T) {
+	codec := GzipCodec{}
+	b, err := codec.Marshal("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewConn(mock("peek 1\r\n", fmt.Sprintf("FOUND 1 %d\r\n%s\r\n", len(b), b)))
+	tube := NewTypedTube[string](c, "default", codec)
+	v, err := tube.Peek(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "hello" {
+		t.Fatalf("expected %#v, got %#v", "hello", v)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTypedTubeSetBuryTampered(t *testing.T) {
+	c := NewConn(mock(
+		"reserve-with-timeout 1\r\nstats-job 1\r\nbury 1 5\r\n",
+		"RESERVED 1 1\r\nx\r\nOK 11\r\n---\npri: 5\n\r\nBURIED\r\n",
+	))
+	ts := NewTypedTubeSet[string](c, SealCodec{Keyring: testKeyring}, "default")
+	ts.BuryTampered = true
+	id, _, err := ts.Reserve(time.Second)
+	if !errors.Is(err, ErrTampered) {
+		t.Fatal("expected ErrTampered, got", err)
+	}
+	if id != 1 {
+		t.Fatal("expected 1, got", id)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}